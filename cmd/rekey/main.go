@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"middleman/pkg/database"
+	"middleman/pkg/utils"
+)
+
+// 更换 ENCRYPTION_KEY（或 BOOTSTRAP_TOKEN）后，使用旧密钥重新加密默认库及各分节点库中的凭据:
+//
+//	rekey -old-key "<旧的 ENCRYPTION_KEY 或 BOOTSTRAP_TOKEN>"
+func main() {
+	oldKey := flag.String("old-key", "", "previous ENCRYPTION_KEY, or BOOTSTRAP_TOKEN if it was not set")
+	dryRun := flag.Bool("dry-run", false, "only report how many rows would be re-encrypted")
+	flag.Parse()
+
+	if *oldKey == "" {
+		log.Fatal("-old-key is required")
+	}
+
+	version, _ := utils.CurrentKey()
+	count, err := database.GetDBManager().ReEncryptSecrets(utils.DeriveKey(*oldKey), *dryRun)
+	if err != nil {
+		// 每个库在单独的事务中处理，已重新加密的行会被跳过，可以直接重试
+		log.Fatalf("Rekey failed, the failed database has not been changed: %v", err)
+	}
+	if *dryRun {
+		log.Printf("%d rows would be re-encrypted with key version %d", count, version)
+		return
+	}
+	log.Printf("%d rows re-encrypted with key version %d", count, version)
+}
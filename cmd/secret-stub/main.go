@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// 本地调试用的 KMS 桩服务，实现 SECRET_BACKEND=http 的接口约定，数据仅保存在内存中:
+//
+//	secret-stub -listen :9999 -token dev
+func main() {
+	listen := flag.String("listen", ":9999", "listen address")
+	token := flag.String("token", "", "required bearer token, empty means no auth")
+	flag.Parse()
+
+	var mu sync.RWMutex
+	secrets := map[string]string{}
+
+	http.HandleFunc("/secrets/", func(w http.ResponseWriter, r *http.Request) {
+		if *token != "" && r.Header.Get("Authorization") != "Bearer "+*token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/secrets/")
+		if name == "" {
+			http.Error(w, "missing secret name", http.StatusBadRequest)
+			return
+		}
+
+		var body struct {
+			Value string `json:"value"`
+		}
+		switch r.Method {
+		case http.MethodPut:
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			mu.Lock()
+			secrets[name] = body.Value
+			mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		case http.MethodGet:
+			mu.RLock()
+			value, exists := secrets[name]
+			mu.RUnlock()
+			if !exists {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			body.Value = value
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(body)
+		case http.MethodDelete:
+			mu.Lock()
+			delete(secrets, name)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	log.Printf("Secret stub listening on %s", *listen)
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
@@ -15,6 +15,24 @@ type Config struct {
 	DBPort         string `mapstructure:"DB_PORT"`
 	DBUser         string `mapstructure:"DB_USER"`
 	DBPwd          string `mapstructure:"DB_PWD"`
+
+	KeyRotationGracePeriod int  `mapstructure:"KEY_ROTATION_GRACE_PERIOD"`
+	AuthAllowBearer        bool `mapstructure:"AUTH_ALLOW_BEARER"`
+	AuthSignatureTTL       int  `mapstructure:"AUTH_SIGNATURE_TTL"`
+	AuthMaxBodySize        int  `mapstructure:"AUTH_MAX_BODY_SIZE"`
+	EnrollmentTokenTTL     int  `mapstructure:"ENROLLMENT_TOKEN_TTL"`
+
+	EncryptionKey        string `mapstructure:"ENCRYPTION_KEY"`
+	EncryptionKeyVersion int    `mapstructure:"ENCRYPTION_KEY_VERSION"`
+
+	SecretBackend      string `mapstructure:"SECRET_BACKEND"`
+	SecretVaultPath    string `mapstructure:"SECRET_VAULT_PATH"`
+	SecretVaultKeyFile string `mapstructure:"SECRET_VAULT_KEY_FILE"`
+	SecretHTTPURL      string `mapstructure:"SECRET_HTTP_URL"`
+	SecretHTTPToken    string `mapstructure:"SECRET_HTTP_TOKEN"`
+
+	HealthCheckInterval int `mapstructure:"HEALTH_CHECK_INTERVAL"`
+	HealthCheckTimeout  int `mapstructure:"HEALTH_CHECK_TIMEOUT"`
 }
 
 var GlobalConfig *Config
@@ -32,6 +50,20 @@ func getDefaultConfig() Config {
 		DBPort:         "5432",
 		DBUser:         "postgres",
 		DBPwd:          "postgres",
+
+		KeyRotationGracePeriod: 86400,
+		AuthAllowBearer:        true,
+		AuthSignatureTTL:       300,
+		AuthMaxBodySize:        32 << 20,
+		EnrollmentTokenTTL:     86400,
+
+		EncryptionKeyVersion: 1,
+
+		SecretBackend:   "inline",
+		SecretVaultPath: "data/vault/secrets.json",
+
+		HealthCheckInterval: 60,
+		HealthCheckTimeout:  10,
 	}
 }
 
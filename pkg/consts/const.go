@@ -2,6 +2,11 @@ package consts
 
 const (
 	DBInfoContextKey     = "database_info"
+	DBTargetsContextKey  = "database_targets"
 	AuthDBInfoContextKey = "auth_database_info"
 	OrgContextKey        = "org_id"
+	AuthKeyContextKey    = "auth_key"
+	// AuthAccessKeyContextKey 认证使用的 access key，可能是节点密钥或 API key
+	AuthAccessKeyContextKey = "auth_access_key"
+	APIKeyContextKey        = "api_key"
 )
@@ -10,6 +10,7 @@ import (
 	_ "github.com/lib/pq"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"middleman/pkg/config"
 	"middleman/pkg/database/models"
@@ -20,25 +21,28 @@ const (
 	DefaultDBName = "middleman"
 )
 
-var DBManager *Manager
+var (
+	DBManager     *Manager
+	dbManagerOnce sync.Once
+)
 
 type Manager struct {
 	dbs map[string]*gorm.DB
 	dsn string
 	mu  sync.RWMutex
+
+	// trgm 记录分节点库是否可以使用 pg_trgm
+	trgm map[string]bool
 }
 
+// GetDBManager 首次使用时连接数据库，不需要数据库的单元测试可以直接导入本包
 func GetDBManager() *Manager {
-	if DBManager == nil {
+	dbManagerOnce.Do(func() {
 		DBManager = newDatabaseManager()
-	}
+	})
 	return DBManager
 }
 
-func init() {
-	DBManager = newDatabaseManager()
-}
-
 func newDatabaseManager() *Manager {
 	conf := config.GetConf()
 	dsn := fmt.Sprintf(
@@ -47,6 +51,7 @@ func newDatabaseManager() *Manager {
 	)
 	dm := &Manager{
 		dbs: make(map[string]*gorm.DB), dsn: dsn,
+		trgm: make(map[string]bool),
 	}
 	if err := dm.initDatabaseManager(); err != nil {
 		log.Fatalf("init database manager failed: %v", err)
@@ -59,7 +64,9 @@ func (dm *Manager) initDatabaseManager() error {
 		return err
 	}
 	db, err := dm.connectDB(DefaultDBName, func(db *gorm.DB) error {
-		return db.AutoMigrate(&mm.JumpServer{})
+		return db.AutoMigrate(
+			&mm.JumpServer{}, &mm.APIKey{}, &mm.EnrollmentToken{}, &mm.SecretRevealLog{},
+		)
 	})
 	if err != nil {
 		return err
@@ -95,14 +102,23 @@ func (dm *Manager) GetDB(name string) (*gorm.DB, error) {
 	}
 
 	db, err = dm.connectDB(name, func(db *gorm.DB) error {
-		return db.AutoMigrate(
+		err := db.AutoMigrate(
 			&models.User{}, &models.Platform{},
 			&models.RbacRole{}, &models.RbacRoleBinding{},
 			&models.Node{}, &models.Asset{}, &models.Host{},
 			&models.Device{}, &models.Database{}, &models.Cloud{},
 			&models.Web{}, &models.GPT{}, &models.Custom{},
-			&models.Account{}, &models.AssetPermission{},
+			&models.Account{}, &models.AssetPermission{}, &models.Organization{},
 		)
+		if err != nil {
+			return err
+		}
+		builtinOrgs := []models.Organization{models.DefaultOrg, models.SystemOrg}
+		if err = db.Clauses(clause.OnConflict{DoNothing: true}).Create(&builtinOrgs).Error; err != nil {
+			return err
+		}
+		dm.trgm[name] = createSearchIndexes(db)
+		return nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to new database %s: %v", name, err)
@@ -115,6 +131,94 @@ func (dm *Manager) GetDB(name string) (*gorm.DB, error) {
 	return db, nil
 }
 
+// HasTrgm 分节点库没有 pg_trgm 扩展时不能按相似度排序
+func (dm *Manager) HasTrgm(name string) bool {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+	return dm.trgm[name]
+}
+
+func (dm *Manager) RemoveDB(name string) error {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	db, exists := dm.dbs[name]
+	if !exists {
+		return nil
+	}
+	delete(dm.dbs, name)
+	delete(dm.trgm, name)
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+func (dm *Manager) DropDatabase(name string) error {
+	if name == DefaultDBName {
+		return fmt.Errorf("database %s can not be dropped", name)
+	}
+	if err := dm.RemoveDB(name); err != nil {
+		return err
+	}
+
+	sqlDB, err := sql.Open("postgres", dm.dsn)
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	if err = terminateConnections(sqlDB, name); err != nil {
+		return err
+	}
+	_, err = sqlDB.Exec(fmt.Sprintf(`DROP DATABASE IF EXISTS "%s"`, name))
+	if err != nil {
+		return fmt.Errorf("failed to drop database: %v", err)
+	}
+	log.Printf("Database %s dropped successfully", name)
+	return nil
+}
+
+func (dm *Manager) ArchiveDatabase(name string) (string, error) {
+	if name == DefaultDBName {
+		return "", fmt.Errorf("database %s can not be archived", name)
+	}
+	if err := dm.RemoveDB(name); err != nil {
+		return "", err
+	}
+
+	sqlDB, err := sql.Open("postgres", dm.dsn)
+	if err != nil {
+		return "", err
+	}
+	defer sqlDB.Close()
+
+	if err = terminateConnections(sqlDB, name); err != nil {
+		return "", err
+	}
+	archivedName := fmt.Sprintf("%s_archived_%s", name, time.Now().Format("20060102150405"))
+	_, err = sqlDB.Exec(fmt.Sprintf(`ALTER DATABASE "%s" RENAME TO "%s"`, name, archivedName))
+	if err != nil {
+		return "", fmt.Errorf("failed to archive database: %v", err)
+	}
+	log.Printf("Database %s archived as %s", name, archivedName)
+	return archivedName, nil
+}
+
+// 重命名或删除数据库前，需要断开其它会话
+func terminateConnections(sqlDB *sql.DB, dbName string) error {
+	_, err := sqlDB.Exec(`
+		SELECT pg_terminate_backend(pid) FROM pg_stat_activity
+		WHERE datname = $1 AND pid <> pg_backend_pid()
+	`, dbName)
+	if err != nil {
+		return fmt.Errorf("terminate connections of %s failed: %v", dbName, err)
+	}
+	return nil
+}
+
 func (dm *Manager) createDatabase(dbName string) error {
 	sqlDB, err := sql.Open("postgres", dm.dsn)
 	if err != nil {
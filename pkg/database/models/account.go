@@ -21,8 +21,27 @@ type Account struct {
 	DateCreated  *UTCTime `json:"date_created,omitempty" gorm:"type:timestamp with time zone;default:null"`
 	DateUpdated  *UTCTime `json:"date_updated,omitempty" gorm:"type:timestamp with time zone;default:null"`
 
+	// MMSecret 中间件加密后的密文，只有查看密文的接口会解密
+	MMSecret string `json:"-" gorm:"column:mm_secret;type:text;default:null"`
+
 	PushNow bool   `json:"push_now" gorm:"-"`
 	Secret  string `json:"secret,omitempty" gorm:"-"`
 
 	Asset Asset `json:"asset,omitempty" gorm:"foreignKey:AssetID;references:ID"`
 }
+
+type JmsAccount struct {
+	Account
+
+	AssetID string `json:"asset"`
+	SuFrom  string `json:"su_from,omitempty"`
+}
+
+// ToJms push_now 由单独的推送任务处理，不随账号一起提交
+func (a Account) ToJms() JmsAccount {
+	assetID, suFrom := a.AssetID, a.SuFromID
+	a.AssetID, a.SuFromID, a.OrgID = "", "", ""
+	a.PushNow = false
+	a.Asset = Asset{}
+	return JmsAccount{Account: a, AssetID: assetID, SuFrom: suFrom}
+}
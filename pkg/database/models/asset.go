@@ -134,20 +134,7 @@ type Host struct {
 }
 
 func (h *Host) UnmarshalJSON(data []byte) error {
-	var reqAsset struct {
-		Asset
-
-		Nodes   []Node   `json:"-"`
-		NodeIds []string `json:"nodes"`
-	}
-	if err := json.Unmarshal(data, &reqAsset); err != nil {
-		return err
-	}
-	h.AssetPtrID = reqAsset.ID
-	h.Asset = reqAsset.Asset
-	h.Asset.OrgID = DefaultOrgID
-	h.Asset.NodeIds = reqAsset.NodeIds
-	return nil
+	return unmarshalAsset(data, &h.AssetPtrID, &h.Asset)
 }
 
 type Device struct {
@@ -182,6 +169,172 @@ type Custom struct {
 	Asset      Asset  `gorm:"foreignKey:AssetPtrID;references:ID"`
 }
 
+// unmarshalAsset 解析请求中平铺的资产字段，类别子表的字段由调用方解析
+func unmarshalAsset(data []byte, ptrID *string, asset *Asset) error {
+	var reqAsset struct {
+		Asset
+
+		Nodes   []Node   `json:"-"`
+		NodeIds []string `json:"nodes"`
+	}
+	if err := json.Unmarshal(data, &reqAsset); err != nil {
+		return err
+	}
+	*ptrID = reqAsset.ID
+	*asset = reqAsset.Asset
+	asset.OrgID = DefaultOrgID
+	asset.NodeIds = reqAsset.NodeIds
+	return nil
+}
+
+func (w *Web) UnmarshalJSON(data []byte) error {
+	type web Web
+	if err := json.Unmarshal(data, (*web)(w)); err != nil {
+		return err
+	}
+	if w.Autofill == "" {
+		w.Autofill = "basic"
+	}
+	return unmarshalAsset(data, &w.AssetPtrID, &w.Asset)
+}
+
+func (w *Web) Validate() error {
+	switch w.Autofill {
+	case "no", "basic", "script":
+		return nil
+	}
+	return fmt.Errorf("autofill must be one of no, basic, script")
+}
+
+func (d *Device) UnmarshalJSON(data []byte) error {
+	return unmarshalAsset(data, &d.AssetPtrID, &d.Asset)
+}
+
+func (d *Database) UnmarshalJSON(data []byte) error {
+	type database Database
+	if err := json.Unmarshal(data, (*database)(d)); err != nil {
+		return err
+	}
+	return unmarshalAsset(data, &d.AssetPtrID, &d.Asset)
+}
+
+func (d *Database) Validate() error {
+	if d.ClientKey != "" && d.ClientCert == "" {
+		return fmt.Errorf("client_cert is required when client_key is set")
+	}
+	return nil
+}
+
+func (c *Cloud) UnmarshalJSON(data []byte) error {
+	return unmarshalAsset(data, &c.AssetPtrID, &c.Asset)
+}
+
+func (g *GPT) UnmarshalJSON(data []byte) error {
+	type gpt GPT
+	if err := json.Unmarshal(data, (*gpt)(g)); err != nil {
+		return err
+	}
+	return unmarshalAsset(data, &g.AssetPtrID, &g.Asset)
+}
+
+func (c *Custom) UnmarshalJSON(data []byte) error {
+	return unmarshalAsset(data, &c.AssetPtrID, &c.Asset)
+}
+
+// TypedAsset 各类别资产子表的公共操作
+type TypedAsset interface {
+	GetAsset() *Asset
+	// Category 平台类别，同时对应 JumpServer 接口 /api/v1/assets/{category}s/
+	Category() string
+}
+
+func (h *Host) GetAsset() *Asset     { return &h.Asset }
+func (w *Web) GetAsset() *Asset      { return &w.Asset }
+func (d *Device) GetAsset() *Asset   { return &d.Asset }
+func (d *Database) GetAsset() *Asset { return &d.Asset }
+func (c *Cloud) GetAsset() *Asset    { return &c.Asset }
+func (g *GPT) GetAsset() *Asset      { return &g.Asset }
+func (c *Custom) GetAsset() *Asset   { return &c.Asset }
+
+func (h *Host) Category() string     { return "host" }
+func (w *Web) Category() string      { return "web" }
+func (d *Device) Category() string   { return "device" }
+func (d *Database) Category() string { return "database" }
+func (c *Cloud) Category() string    { return "cloud" }
+func (g *GPT) Category() string      { return "gpt" }
+func (c *Custom) Category() string   { return "custom" }
+
+// NewTypedAsset 按平台类别创建对应的资产子表对象
+func NewTypedAsset(category string) (TypedAsset, error) {
+	switch category {
+	case "host":
+		return &Host{}, nil
+	case "web":
+		return &Web{}, nil
+	case "device":
+		return &Device{}, nil
+	case "database":
+		return &Database{}, nil
+	case "cloud":
+		return &Cloud{}, nil
+	case "gpt":
+		return &GPT{}, nil
+	case "custom":
+		return &Custom{}, nil
+	}
+	return nil, fmt.Errorf("unknown asset category: %s", category)
+}
+
+// assetSecretFields 子表中的敏感字段，只推送到 JumpServer，不在接口中返回
+var assetSecretFields = []string{"client_key"}
+
+func assetFields(asset TypedAsset) (map[string]interface{}, error) {
+	data, err := json.Marshal(asset)
+	if err != nil {
+		return nil, err
+	}
+	spec := map[string]interface{}{}
+	if err = json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"asset", "Asset", "asset_ptr_id", "AssetPtrID"} {
+		delete(spec, key)
+	}
+	return spec, nil
+}
+
+// AssetSpec 返回资产子表中除资产本身及敏感字段以外的字段
+func AssetSpec(asset TypedAsset) (map[string]interface{}, error) {
+	spec, err := assetFields(asset)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range assetSecretFields {
+		delete(spec, key)
+	}
+	return spec, nil
+}
+
+// ToJmsAsset 合并资产及其类别字段，作为推送到 JumpServer 的请求体
+func ToJmsAsset(asset TypedAsset) (map[string]interface{}, error) {
+	data, err := json.Marshal(asset.GetAsset().ToJms())
+	if err != nil {
+		return nil, err
+	}
+	payload := map[string]interface{}{}
+	if err = json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	spec, err := assetFields(asset)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range spec {
+		payload[key] = value
+	}
+	return payload, nil
+}
+
 type SimpleNode struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
@@ -74,6 +74,16 @@ func (sa *StringArray) Scan(value interface{}) error {
 	return json.Unmarshal(value.([]byte), &sa)
 }
 
+// ParseTime 解析查询参数中的时间，额外支持只有日期的格式
+func ParseTime(text string) (time.Time, error) {
+	for _, format := range append(supportedTimeFormat, "2006-01-02") {
+		if t, err := time.Parse(format, text); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("不支持的时间格式: %s", text)
+}
+
 type UTCTime struct {
 	time.Time
 }
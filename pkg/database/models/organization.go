@@ -1,32 +1,47 @@
 package models
 
-import "time"
+const (
+	// RootOrgID JumpServer 的全局组织，在该组织下删除用户才会真正删除
+	RootOrgID   = "00000000-0000-0000-0000-000000000000"
+	SystemOrgID = "00000000-0000-0000-0000-000000000004"
+)
 
 type Organization struct {
-	ID          string     `json:"id"`
-	Name        string     `json:"name"`
-	CreatedBy   string     `json:"created_by,omitempty"`
-	DateCreated *time.Time `json:"date_created,omitempty"`
-	Comment     string     `json:"comment"`
-	Builtin     bool       `json:"builtin"`
-	DateUpdated time.Time  `json:"date_updated"`
-	UpdatedBy   *string    `json:"updated_by,omitempty"`
+	ID          string   `json:"id" gorm:"type:uuid;primaryKey;not null"`
+	Name        string   `json:"name" gorm:"type:varchar(128);not null;unique"`
+	Comment     string   `json:"comment" gorm:"type:text"`
+	Builtin     bool     `json:"builtin" gorm:"type:boolean;default:false"`
+	CreatedBy   string   `json:"created_by,omitempty" gorm:"type:varchar(128);default:null"`
+	UpdatedBy   string   `json:"updated_by,omitempty" gorm:"type:varchar(128);default:null"`
+	DateCreated *UTCTime `json:"date_created,omitempty" gorm:"type:timestamp with time zone;default:null"`
+	DateUpdated *UTCTime `json:"date_updated,omitempty" gorm:"type:timestamp with time zone;default:null"`
 }
 
+func (Organization) TableName() string {
+	return "orgs_organization"
+}
+
+type JMSOrganization struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Comment string `json:"comment"`
+}
+
+func (o *Organization) ToJms() JMSOrganization {
+	return JMSOrganization{ID: o.ID, Name: o.Name, Comment: o.Comment}
+}
+
+// 内置组织，创建分节点数据库时写入
 var DefaultOrg = Organization{
-	ID:        "00000000000000000000000000000002",
+	ID:        DefaultOrgID,
 	Name:      "Default",
 	CreatedBy: "System",
-	Comment:   "",
 	Builtin:   true,
-	UpdatedBy: nil,
 }
 
 var SystemOrg = Organization{
-	ID:        "00000000000000000000000000000004",
+	ID:        SystemOrgID,
 	Name:      "SYSTEM",
 	CreatedBy: "System",
-	Comment:   "",
 	Builtin:   true,
-	UpdatedBy: nil,
 }
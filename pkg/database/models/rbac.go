@@ -1,19 +1,42 @@
 package models
 
+const (
+	SystemRoleScope = "system"
+	OrgRoleScope    = "org"
+
+	SystemAdminRoleID = "00000000-0000-0000-0000-000000000001"
+)
+
 type RbacRoleBinding struct {
 	ID          string   `json:"id" gorm:"type:uuid;primaryKey;not null"`
 	Scope       string   `json:"scope" gorm:"type:varchar(128);not null"`
-	OrgID       string   `json:"org_id" gorm:"type:uuid;not null;index"`
-	RoleID      string   `json:"role_id" gorm:"type:uuid;not null;index"`
-	UserID      string   `json:"user_id" gorm:"type:uuid;not null;index"`
+	OrgID       string   `json:"org_id" gorm:"type:uuid;not null;index;uniqueIndex:idx_rbac_role_binding_unique"`
+	RoleID      string   `json:"role_id" gorm:"type:uuid;not null;index;uniqueIndex:idx_rbac_role_binding_unique"`
+	UserID      string   `json:"user_id" gorm:"type:uuid;not null;index;uniqueIndex:idx_rbac_role_binding_unique"`
 	Comment     string   `json:"comment" gorm:"type:text"`
 	CreatedBy   string   `json:"created_by" gorm:"type:varchar(128);default:null"`
 	UpdatedBy   string   `json:"updated_by" gorm:"type:varchar(128);default:null"`
 	DateCreated *UTCTime `json:"date_created" gorm:"type:timestamp with time zone;default:null"`
 	DateUpdated *UTCTime `json:"date_updated" gorm:"type:timestamp with time zone;default null"`
 
-	Role RbacRole `gorm:"foreignKey:RoleID;references:ID"`
-	User User     `gorm:"foreignKey:UserID;references:ID"`
+	Role RbacRole `json:"-" gorm:"foreignKey:RoleID;references:ID"`
+	User User     `json:"-" gorm:"foreignKey:UserID;references:ID"`
+}
+
+type JMSRoleBinding struct {
+	ID   string `json:"id"`
+	User string `json:"user"`
+	Role string `json:"role"`
+	Org  string `json:"org,omitempty"`
+}
+
+// ToJms 系统角色的绑定不属于任何组织
+func (b *RbacRoleBinding) ToJms() JMSRoleBinding {
+	binding := JMSRoleBinding{ID: b.ID, User: b.UserID, Role: b.RoleID}
+	if b.Scope == OrgRoleScope {
+		binding.Org = b.OrgID
+	}
+	return binding
 }
 
 type RbacRole struct {
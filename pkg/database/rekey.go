@@ -0,0 +1,102 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	mm "middleman/pkg/middleware/models"
+	"middleman/pkg/utils"
+)
+
+type encryptedColumns struct {
+	table   string
+	columns []string
+}
+
+// 默认库中需要重新加密的字段
+var encryptedTables = []encryptedColumns{
+	{table: "jump_servers", columns: []string{"private_token", "secret_key", "prev_secret_key"}},
+	{table: "api_keys", columns: []string{"secret_key"}},
+}
+
+// 分节点库中需要重新加密的字段
+var slaveEncryptedTables = []encryptedColumns{
+	{table: "accounts", columns: []string{"mm_secret"}},
+}
+
+// ReEncryptSecrets 使用旧密钥解密默认库及各分节点库中的凭据，并以当前密钥重新加密，返回更新的行数
+func (dm *Manager) ReEncryptSecrets(oldKey []byte, dryRun bool) (int, error) {
+	defaultDB := dm.GetDefaultDB()
+	updated, err := reEncryptTables(defaultDB, encryptedTables, oldKey, dryRun)
+	if err != nil {
+		return 0, err
+	}
+
+	// 只读取名称，加载完整模型会在 AfterFind 中用当前密钥解密尚未重新加密的字段
+	var names []string
+	if err = defaultDB.Model(&mm.JumpServer{}).Where("role = ?", mm.RoleSlave).
+		Order("name").Pluck("name", &names).Error; err != nil {
+		return updated, err
+	}
+	for _, name := range names {
+		db, err := dm.GetDB(name)
+		if err != nil {
+			return updated, err
+		}
+		count, err := reEncryptTables(db, slaveEncryptedTables, oldKey, dryRun)
+		if err != nil {
+			return updated, fmt.Errorf("slave %s: %w", name, err)
+		}
+		updated += count
+	}
+	return updated, nil
+}
+
+func reEncryptTables(db *gorm.DB, tables []encryptedColumns, oldKey []byte, dryRun bool) (int, error) {
+	updated := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, t := range tables {
+			// 使用 map 读取，跳过模型的 AfterFind 解密
+			var rows []map[string]interface{}
+			selects := append([]string{"id"}, t.columns...)
+			if err := tx.Table(t.table).Select(selects).Order("id").Find(&rows).Error; err != nil {
+				return err
+			}
+
+			for _, row := range rows {
+				changes := map[string]interface{}{}
+				for _, column := range t.columns {
+					text, _ := row[column].(string)
+					// 外部 SecretBackend 的引用不在此处加密
+					if text == "" || utils.IsExternalSecret(text) {
+						continue
+					}
+					ciphertext, changed, err := utils.ReEncryptString(text, oldKey)
+					if err != nil {
+						return fmt.Errorf("%s id=%v column %s: %w", t.table, row["id"], column, err)
+					}
+					if changed {
+						changes[column] = ciphertext
+					}
+				}
+				if len(changes) == 0 {
+					continue
+				}
+				updated++
+				if dryRun {
+					continue
+				}
+				if err := tx.Table(t.table).Where("id = ?", row["id"]).
+					UpdateColumns(changes).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
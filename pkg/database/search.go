@@ -0,0 +1,47 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// searchIndexes 分节点库中用于模糊搜索的字段，需与各资源的 searchFields 保持一致
+var searchIndexes = []struct {
+	table  string
+	fields []string
+}{
+	{"users", []string{"username", "name", "email"}},
+	{"platforms", []string{"name", "type", "category"}},
+	{"assets", []string{"address", "name"}},
+	{"accounts", []string{"username", "name"}},
+	{"perms_assetpermission", []string{"name"}},
+	{"assets_node", []string{"value", "full_value"}},
+}
+
+// createSearchIndexes 为 ILIKE 搜索创建 pg_trgm GIN 索引，返回 pg_trgm 是否可用。
+// 数据库账号无权创建扩展时仅记录日志，搜索仍然可用但不会走索引，也不能按相似度排序
+func createSearchIndexes(db *gorm.DB) bool {
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		log.Printf("Create extension pg_trgm failed, search will not use indexes: %v", err)
+		// 扩展可能已由管理员创建
+		var installed bool
+		if err = db.Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm')").
+			Scan(&installed).Error; err != nil || !installed {
+			return false
+		}
+	}
+	for _, index := range searchIndexes {
+		for _, field := range index.fields {
+			sql := fmt.Sprintf(
+				"CREATE INDEX IF NOT EXISTS idx_%s_%s_trgm ON %s USING gin (%s gin_trgm_ops)",
+				index.table, field, index.table, field,
+			)
+			if err := db.Exec(sql).Error; err != nil {
+				log.Printf("Create search index on %s.%s failed: %v", index.table, field, err)
+			}
+		}
+	}
+	return true
+}
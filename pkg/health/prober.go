@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"middleman/pkg/config"
+	"middleman/pkg/database"
+	"middleman/pkg/middleware/models"
+	"middleman/pkg/utils"
+)
+
+// Prober 定期探测各分节点的 JumpServer，并把结果记录到节点信息中
+type Prober struct {
+	interval time.Duration
+	timeout  time.Duration
+	logger   *utils.Logger
+}
+
+func NewProber() *Prober {
+	conf := config.GetConf()
+	return &Prober{
+		interval: time.Duration(conf.HealthCheckInterval) * time.Second,
+		timeout:  time.Duration(conf.HealthCheckTimeout) * time.Second,
+		logger:   utils.GetLogger(),
+	}
+}
+
+func (p *Prober) Start(ctx context.Context) {
+	if p.interval <= 0 {
+		p.logger.Info("Health check is disabled")
+		return
+	}
+	go p.probeWorker(ctx)
+}
+
+func (p *Prober) probeWorker(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	p.logger.Debug("Start worker -> [prober]")
+	p.probeAll(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.Info(" Worker [prober] is exiting.")
+			return
+		case <-ticker.C:
+			p.probeAll(ctx)
+		}
+	}
+}
+
+func (p *Prober) probeAll(ctx context.Context) {
+	db := database.GetDBManager().GetDefaultDB()
+	var servers []models.JumpServer
+	if err := db.Where("role = ?", models.RoleSlave).Find(&servers).Error; err != nil {
+		p.logger.Error("Load slave nodes failed: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func(server models.JumpServer) {
+			defer wg.Done()
+			p.probe(ctx, server)
+		}(server)
+	}
+	wg.Wait()
+}
+
+func (p *Prober) probe(ctx context.Context, server models.JumpServer) {
+	probeCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	start := time.Now()
+	var version string
+	privateToken, err := server.GetPrivateToken()
+	if err == nil {
+		version, err = utils.NewJumpServer(server.Endpoint, privateToken).Ping(probeCtx)
+	}
+	now := time.Now()
+
+	columns := map[string]interface{}{
+		"latency":         now.Sub(start).Milliseconds(),
+		"last_checked_at": now,
+	}
+	if err != nil {
+		p.logger.Warn("Slave node %s is unhealthy: %v", server.Name, err)
+		columns["status"] = models.HealthUnhealthy
+		columns["last_error"] = err.Error()
+	} else {
+		columns["status"] = models.HealthHealthy
+		columns["last_error"] = ""
+		columns["last_seen_at"] = now
+		if version != "" {
+			columns["version"] = version
+		}
+	}
+
+	// UpdateColumns 不触发加解密钩子
+	db := database.GetDBManager().GetDefaultDB()
+	err = db.Model(&models.JumpServer{}).Where("id = ?", server.ID).UpdateColumns(columns).Error
+	if err != nil {
+		p.logger.Error("Save health of %s failed: %v", server.Name, err)
+	}
+}
@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Relation 可以通过 expand 预加载的关联，Keys 为该关联在响应中对应的字段
+type Relation struct {
+	Preload string
+	Scope   func(db *gorm.DB) *gorm.DB
+	Keys    []string
+}
+
+type Relations map[string]Relation
+
+func splitParam(text string) []string {
+	var items []string
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// handleExpand 预加载 expand 中列出的关联，未传 expand 时预加载 defaults，expand= 表示不加载任何关联
+func (h *ResourcesHandler) handleExpand(
+	c *gin.Context, q *gorm.DB, relations Relations, defaults []string,
+) (*gorm.DB, error) {
+	names := defaults
+	if text, exists := c.GetQuery("expand"); exists {
+		names = splitParam(text)
+	}
+
+	h.expandKeys = nil
+	for _, name := range names {
+		relation, exists := relations[name]
+		if !exists {
+			return nil, &FilterError{Param: "expand", Msg: fmt.Sprintf("unknown relation %s", name)}
+		}
+		if relation.Scope != nil {
+			q = q.Preload(relation.Preload, relation.Scope)
+		} else {
+			q = q.Preload(relation.Preload)
+		}
+		h.expandKeys = append(h.expandKeys, relation.Keys...)
+	}
+	return q, nil
+}
+
+// handleFields 传入 fields 时只查询其中的数据库字段，以及关联和计算字段依赖的 required 字段
+func (h *ResourcesHandler) handleFields(
+	c *gin.Context, q *gorm.DB, table string, model interface{}, required []string,
+) (*gorm.DB, error) {
+	h.fields = splitParam(c.Query("fields"))
+	if len(h.fields) == 0 {
+		return q, nil
+	}
+
+	stmt := &gorm.Statement{DB: h.db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	// 排序字段用于生成游标，同样需要查询
+	var orders []string
+	for _, o := range splitParam(c.Query("order")) {
+		orders = append(orders, strings.TrimPrefix(o, "-"))
+	}
+
+	var columns []string
+	selected := map[string]bool{}
+	for _, group := range [][]string{required, h.fields, orders} {
+		for _, name := range group {
+			if _, exists := stmt.Schema.FieldsByDBName[name]; !exists || selected[name] {
+				continue
+			}
+			selected[name] = true
+			columns = append(columns, fmt.Sprintf("%s.%s", table, name))
+		}
+	}
+	return q.Select(columns), nil
+}
+
+// projectFields 只保留 fields 及已展开关联对应的字段，extra 为额外附加的字段
+func (h *ResourcesHandler) projectFields(resources interface{}, extra ...string) (interface{}, error) {
+	if len(h.fields) == 0 {
+		return resources, nil
+	}
+	data, err := json.Marshal(resources)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]interface{}
+	if err = json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+
+	keep := map[string]bool{}
+	for _, group := range [][]string{h.fields, h.expandKeys, extra} {
+		for _, key := range group {
+			keep[key] = true
+		}
+	}
+	for _, row := range rows {
+		for key := range row {
+			if !keep[key] {
+				delete(row, key)
+			}
+		}
+	}
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+	return rows, nil
+}
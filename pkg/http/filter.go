@@ -0,0 +1,168 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"middleman/pkg/database/models"
+)
+
+type FieldKind int
+
+const (
+	ExactField FieldKind = iota
+	TextField
+	BoolField
+	NumberField
+	TimeField
+)
+
+const (
+	opExact      = ""
+	opIn         = "in"
+	opContains   = "contains"
+	opIContains  = "icontains"
+	opStartsWith = "startswith"
+	opEndsWith   = "endswith"
+	opGt         = "gt"
+	opGte        = "gte"
+	opLt         = "lt"
+	opLte        = "lte"
+	opRange      = "range"
+	opIsNull     = "isnull"
+)
+
+// 每种字段类型支持的过滤操作，查询参数格式为 field__op=value
+var fieldOperators = map[FieldKind]map[string]bool{
+	ExactField: {opExact: true, opIn: true, opIsNull: true},
+	TextField: {
+		opExact: true, opIn: true, opIsNull: true,
+		opContains: true, opIContains: true, opStartsWith: true, opEndsWith: true,
+	},
+	BoolField: {opExact: true, opIsNull: true},
+	NumberField: {
+		opExact: true, opIn: true, opIsNull: true,
+		opGt: true, opGte: true, opLt: true, opLte: true, opRange: true,
+	},
+	TimeField: {
+		opIsNull: true,
+		opGt:     true, opGte: true, opLt: true, opLte: true, opRange: true,
+	},
+}
+
+// FilterFields 允许过滤的字段及其类型
+type FilterFields map[string]FieldKind
+
+// FilterError 查询参数不合法，调用方应返回 400
+type FilterError struct {
+	Param string
+	Msg   string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("invalid filter %s: %s", e.Param, e.Msg)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(text string) string {
+	return likeEscaper.Replace(text)
+}
+
+func parseFilterValue(kind FieldKind, text string) (interface{}, error) {
+	switch kind {
+	case BoolField:
+		return strconv.ParseBool(text)
+	case NumberField:
+		return strconv.ParseFloat(text, 64)
+	case TimeField:
+		return models.ParseTime(text)
+	default:
+		return text, nil
+	}
+}
+
+func parseFilterValues(kind FieldKind, text string) ([]interface{}, error) {
+	var values []interface{}
+	for _, item := range strings.Split(text, ",") {
+		value, err := parseFilterValue(kind, strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// handleFilter 根据查询参数添加过滤条件，未声明的字段会被忽略，
+// 字段名使用 table 限定，避免关联查询时出现歧义
+func (h *ResourcesHandler) handleFilter(
+	c *gin.Context, q *gorm.DB, table string, fields FilterFields,
+) (*gorm.DB, error) {
+	for key, values := range c.Request.URL.Query() {
+		if h.processedParams[key] || len(values) == 0 {
+			continue
+		}
+		field, op := key, opExact
+		if idx := strings.Index(key, "__"); idx > 0 {
+			field, op = key[:idx], key[idx+2:]
+		}
+		kind, exists := fields[field]
+		if !exists {
+			continue
+		}
+		if !fieldOperators[kind][op] {
+			return nil, &FilterError{Param: key, Msg: fmt.Sprintf("operator not supported on %s", field)}
+		}
+
+		text := values[len(values)-1]
+		column := fmt.Sprintf("%s.%s", table, field)
+		switch op {
+		case opIsNull:
+			isNull, err := strconv.ParseBool(text)
+			if err != nil {
+				return nil, &FilterError{Param: key, Msg: "value must be true or false"}
+			}
+			if isNull {
+				q = q.Where(fmt.Sprintf("%s IS NULL", column))
+			} else {
+				q = q.Where(fmt.Sprintf("%s IS NOT NULL", column))
+			}
+		case opContains:
+			q = q.Where(fmt.Sprintf("%s LIKE ?", column), "%"+escapeLike(text)+"%")
+		case opIContains:
+			q = q.Where(fmt.Sprintf("%s ILIKE ?", column), "%"+escapeLike(text)+"%")
+		case opStartsWith:
+			q = q.Where(fmt.Sprintf("%s LIKE ?", column), escapeLike(text)+"%")
+		case opEndsWith:
+			q = q.Where(fmt.Sprintf("%s LIKE ?", column), "%"+escapeLike(text))
+		case opIn, opRange:
+			args, err := parseFilterValues(kind, text)
+			if err != nil {
+				return nil, &FilterError{Param: key, Msg: err.Error()}
+			}
+			if op == opIn {
+				q = q.Where(fmt.Sprintf("%s IN ?", column), args)
+				continue
+			}
+			if len(args) != 2 {
+				return nil, &FilterError{Param: key, Msg: "range requires two values separated by comma"}
+			}
+			q = q.Where(fmt.Sprintf("%s BETWEEN ? AND ?", column), args[0], args[1])
+		default:
+			arg, err := parseFilterValue(kind, text)
+			if err != nil {
+				return nil, &FilterError{Param: key, Msg: err.Error()}
+			}
+			operators := map[string]string{
+				opExact: "=", opGt: ">", opGte: ">=", opLt: "<", opLte: "<=",
+			}
+			q = q.Where(fmt.Sprintf("%s %s ?", column, operators[op]), arg)
+		}
+	}
+	return q, nil
+}
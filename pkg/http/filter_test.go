@@ -0,0 +1,87 @@
+package pkg
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+var filterFields = FilterFields{
+	"id":           ExactField,
+	"name":         TextField,
+	"is_active":    BoolField,
+	"port":         NumberField,
+	"date_created": TimeField,
+}
+
+func TestHandleFilter(t *testing.T) {
+	db := newDryRunDB(t)
+
+	tests := []struct {
+		name      string
+		query     url.Values
+		want      string
+		wantErr   bool
+		wantParam string
+	}{
+		{name: "exact", query: url.Values{"name": {"web"}}, want: "WHERE t.name = 'web'"},
+		{name: "last value wins", query: url.Values{"name": {"a", "b"}}, want: "WHERE t.name = 'b'"},
+		{name: "in", query: url.Values{"id__in": {"a, b"}}, want: "WHERE t.id IN ('a','b')"},
+		{name: "contains escapes like", query: url.Values{"name__contains": {"50%_a"}},
+			want: `WHERE t.name LIKE '%50\%\_a%'`},
+		{name: "icontains", query: url.Values{"name__icontains": {"Web"}}, want: "WHERE t.name ILIKE '%Web%'"},
+		{name: "startswith", query: url.Values{"name__startswith": {"web"}}, want: "WHERE t.name LIKE 'web%'"},
+		{name: "endswith", query: url.Values{"name__endswith": {"web"}}, want: "WHERE t.name LIKE '%web'"},
+		{name: "bool", query: url.Values{"is_active": {"false"}}, want: "WHERE t.is_active = false"},
+		{name: "number gte", query: url.Values{"port__gte": {"22"}}, want: "WHERE t.port >= 22"},
+		{name: "number range", query: url.Values{"port__range": {"22,80"}}, want: "WHERE t.port BETWEEN 22 AND 80"},
+		{name: "time lt", query: url.Values{"date_created__lt": {"2024-01-02"}},
+			want: "WHERE t.date_created < '2024-01-02 00:00:00"},
+		{name: "isnull", query: url.Values{"date_created__isnull": {"true"}}, want: "WHERE t.date_created IS NULL"},
+		{name: "not null", query: url.Values{"name__isnull": {"0"}}, want: "WHERE t.name IS NOT NULL"},
+		{name: "unknown field ignored", query: url.Values{"secret": {"x"}, "limit": {"10"}}, want: "SELECT * FROM"},
+		{name: "unsupported operator", query: url.Values{"is_active__gt": {"1"}},
+			wantErr: true, wantParam: "is_active__gt"},
+		{name: "unknown operator", query: url.Values{"name__regex": {"."}}, wantErr: true, wantParam: "name__regex"},
+		{name: "time exact not allowed", query: url.Values{"date_created": {"2024-01-02"}},
+			wantErr: true, wantParam: "date_created"},
+		{name: "invalid bool", query: url.Values{"is_active": {"maybe"}}, wantErr: true, wantParam: "is_active"},
+		{name: "invalid number", query: url.Values{"port__in": {"22,ssh"}}, wantErr: true, wantParam: "port__in"},
+		{name: "invalid time", query: url.Values{"date_created__gt": {"yesterday"}},
+			wantErr: true, wantParam: "date_created__gt"},
+		{name: "invalid isnull", query: url.Values{"name__isnull": {"maybe"}}, wantErr: true, wantParam: "name__isnull"},
+		{name: "range needs two values", query: url.Values{"port__range": {"22"}}, wantErr: true, wantParam: "port__range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ResourcesHandler{db: db, processedParams: map[string]bool{"limit": true}}
+			c := newQueryContext(tt.query.Encode())
+			var err error
+			sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				var q *gorm.DB
+				q, err = h.handleFilter(c, tx.Table("t"), "t", filterFields)
+				if err != nil {
+					return tx
+				}
+				return q.Find(&[]map[string]interface{}{})
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("handleFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if fe, ok := err.(*FilterError); !ok || fe.Param != tt.wantParam {
+					t.Errorf("handleFilter() error = %#v, want FilterError on %s", err, tt.wantParam)
+				}
+				return
+			}
+			if !strings.Contains(sql, tt.want) {
+				t.Errorf("handleFilter() sql = %s, want to contain %s", sql, tt.want)
+			}
+			if tt.want == "SELECT * FROM" && strings.Contains(sql, "WHERE") {
+				t.Errorf("handleFilter() sql = %s, want no condition", sql)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package pkg
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type orderField struct {
+	name string
+	desc bool
+}
+
+// cursorToken 游标中保存上一页最后一条记录的排序字段值，对调用方是不透明的
+type cursorToken struct {
+	Order  string        `json:"o"`
+	Values []interface{} `json:"v"`
+}
+
+// parseOrder 解析 order=name,-date_created，- 表示降序，始终以 id 作为最后的排序字段
+func parseOrder(text string, fields FilterFields) ([]orderField, error) {
+	var orders []orderField
+	seen := map[string]bool{}
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		o := orderField{name: strings.TrimPrefix(item, "-"), desc: strings.HasPrefix(item, "-")}
+		if _, exists := fields[o.name]; !exists {
+			return nil, &FilterError{Param: "order", Msg: fmt.Sprintf("can not order by %s", o.name)}
+		}
+		if seen[o.name] {
+			continue
+		}
+		seen[o.name] = true
+		orders = append(orders, o)
+	}
+	if !seen["id"] {
+		orders = append(orders, orderField{name: "id"})
+	}
+	return orders, nil
+}
+
+func orderString(orders []orderField) string {
+	var items []string
+	for _, o := range orders {
+		if o.desc {
+			items = append(items, "-"+o.name)
+		} else {
+			items = append(items, o.name)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+func decodeCursor(text string) (cursorToken, error) {
+	var token cursorToken
+	data, err := base64.RawURLEncoding.DecodeString(text)
+	if err != nil {
+		return token, err
+	}
+	err = json.Unmarshal(data, &token)
+	return token, err
+}
+
+// cursorCondition 生成"位于游标之后"的条件。PostgreSQL 升序时 NULL 排在最后，降序时排在最前
+func cursorCondition(table string, orders []orderField, values []interface{}) (string, []interface{}) {
+	var ors, equals []string
+	var args, equalArgs []interface{}
+	for i, o := range orders {
+		column := fmt.Sprintf("%s.%s", table, o.name)
+		value := values[i]
+
+		var after string
+		var afterArgs []interface{}
+		switch {
+		case value == nil && o.desc:
+			after = fmt.Sprintf("%s IS NOT NULL", column)
+		case value == nil:
+		case o.desc:
+			after, afterArgs = fmt.Sprintf("%s < ?", column), []interface{}{value}
+		default:
+			after, afterArgs = fmt.Sprintf("(%s > ? OR %s IS NULL)", column, column), []interface{}{value}
+		}
+		if after != "" {
+			conditions := append(append([]string{}, equals...), after)
+			ors = append(ors, "("+strings.Join(conditions, " AND ")+")")
+			args = append(append(args, equalArgs...), afterArgs...)
+		}
+
+		if value == nil {
+			equals = append(equals, fmt.Sprintf("%s IS NULL", column))
+		} else {
+			equals = append(equals, fmt.Sprintf("%s = ?", column))
+			equalArgs = append(equalArgs, value)
+		}
+	}
+	if len(ors) == 0 {
+		return "1 = 0", nil
+	}
+	return strings.Join(ors, " OR "), args
+}
+
+// handlePage 添加排序及分页条件，传入 cursor 时使用 keyset 分页并忽略 offset
+func (h *ResourcesHandler) handlePage(
+	c *gin.Context, q *gorm.DB, table string, fields FilterFields, limit, offset int,
+) (*gorm.DB, error) {
+	orders, err := parseOrder(c.Query("order"), fields)
+	if err != nil {
+		return nil, err
+	}
+	h.pageOrders, h.pageLimit, h.nextCursor = orders, limit, ""
+	for _, o := range orders {
+		q = q.Order(clause.OrderByColumn{
+			Column: clause.Column{Table: table, Name: o.name}, Desc: o.desc,
+		})
+	}
+
+	cursor := c.Query("cursor")
+	if cursor == "" {
+		return q.Limit(limit).Offset(offset), nil
+	}
+	if ranking, _ := strconv.ParseBool(c.Query("ranking")); ranking {
+		return nil, &FilterError{Param: "cursor", Msg: "can not be used with ranking"}
+	}
+	token, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, &FilterError{Param: "cursor", Msg: "malformed cursor"}
+	}
+	if token.Order != orderString(orders) || len(token.Values) != len(orders) {
+		return nil, &FilterError{Param: "cursor", Msg: "cursor does not match the order"}
+	}
+	condition, args := cursorCondition(table, orders, token.Values)
+	return q.Where("("+condition+")", args...).Limit(limit), nil
+}
+
+// countPage 游标分页只在第一页统计总数，后续页不再执行 count 查询，响应中也不返回 count
+func (h *ResourcesHandler) countPage(c *gin.Context, q *gorm.DB) (int64, error) {
+	h.skipCount = c.Query("cursor") != ""
+	if h.skipCount {
+		return 0, nil
+	}
+	var count int64
+	err := q.Count(&count).Error
+	return count, err
+}
+
+// setNextCursor 当前页已满时，根据最后一条记录生成下一页的游标
+func (h *ResourcesHandler) setNextCursor(rows interface{}) {
+	h.nextCursor = ""
+	rv := reflect.Indirect(reflect.ValueOf(rows))
+	if h.pageLimit <= 0 || rv.Len() < h.pageLimit {
+		return
+	}
+
+	stmt := &gorm.Statement{DB: h.db}
+	if err := stmt.Parse(rows); err != nil {
+		return
+	}
+	last := rv.Index(rv.Len() - 1)
+	token := cursorToken{Order: orderString(h.pageOrders)}
+	for _, o := range h.pageOrders {
+		field := stmt.Schema.LookUpField(o.name)
+		if field == nil {
+			return
+		}
+		value, _ := field.ValueOf(context.Background(), last)
+		if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr && v.IsNil() {
+			value = nil
+		}
+		token.Values = append(token.Values, value)
+	}
+	data, err := json.Marshal(token)
+	if err != nil {
+		return
+	}
+	h.nextCursor = base64.RawURLEncoding.EncodeToString(data)
+}
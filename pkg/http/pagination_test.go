@@ -0,0 +1,261 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type pageRow struct {
+	ID          string
+	Name        string
+	DateCreated *time.Time
+}
+
+func (pageRow) TableName() string {
+	return "page_rows"
+}
+
+var pageFields = FilterFields{"id": ExactField, "name": TextField, "date_created": TimeField}
+
+func encodeCursor(t *testing.T, token cursorToken) string {
+	t.Helper()
+	data, err := json.Marshal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestParseOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", text: "", want: "id"},
+		{name: "ascending", text: "name", want: "name,id"},
+		{name: "descending", text: "-date_created", want: "-date_created,id"},
+		{name: "multiple", text: "name, -date_created", want: "name,-date_created,id"},
+		{name: "explicit id", text: "-id,name", want: "-id,name"},
+		{name: "duplicate", text: "name,-name", want: "name,id"},
+		{name: "empty items", text: ",name,,", want: "name,id"},
+		{name: "unknown field", text: "password", wantErr: true},
+		{name: "unknown descending field", text: "-password", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, err := parseOrder(tt.text, pageFields)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseOrder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := orderString(orders); got != tt.want {
+				t.Errorf("parseOrder() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCursorCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		orders   []orderField
+		values   []interface{}
+		want     string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "id only",
+			orders:   []orderField{{name: "id"}},
+			values:   []interface{}{"b"},
+			want:     "((t.id > ? OR t.id IS NULL))",
+			wantArgs: []interface{}{"b"},
+		},
+		{
+			name:     "descending",
+			orders:   []orderField{{name: "name", desc: true}, {name: "id"}},
+			values:   []interface{}{"n", "b"},
+			want:     "(t.name < ?) OR (t.name = ? AND (t.id > ? OR t.id IS NULL))",
+			wantArgs: []interface{}{"n", "n", "b"},
+		},
+		{
+			name:     "ascending null is last",
+			orders:   []orderField{{name: "name"}, {name: "id"}},
+			values:   []interface{}{nil, "b"},
+			want:     "(t.name IS NULL AND (t.id > ? OR t.id IS NULL))",
+			wantArgs: []interface{}{"b"},
+		},
+		{
+			name:     "descending null is first",
+			orders:   []orderField{{name: "name", desc: true}, {name: "id"}},
+			values:   []interface{}{nil, "b"},
+			want:     "(t.name IS NOT NULL) OR (t.name IS NULL AND (t.id > ? OR t.id IS NULL))",
+			wantArgs: []interface{}{"b"},
+		},
+		{
+			name:   "nothing after",
+			orders: []orderField{{name: "id"}},
+			values: []interface{}{nil},
+			want:   "1 = 0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, args := cursorCondition("t", tt.orders, tt.values)
+			if got != tt.want {
+				t.Errorf("cursorCondition() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("cursorCondition() args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestHandlePage(t *testing.T) {
+	db := newDryRunDB(t)
+	validCursor := encodeCursor(t, cursorToken{Order: "-name,id", Values: []interface{}{"n", "b"}})
+
+	tests := []struct {
+		name      string
+		query     string
+		want      []string
+		wantErr   bool
+		wantParam string
+	}{
+		{
+			name:  "offset",
+			query: "order=-name",
+			want:  []string{`ORDER BY "page_rows"."name" DESC,"page_rows"."id"`, "LIMIT 10 OFFSET 20"},
+		},
+		{
+			name:  "cursor ignores offset",
+			query: "order=-name&cursor=" + validCursor,
+			want:  []string{`WHERE ((page_rows.name < 'n') OR (page_rows.name = 'n' AND`, "LIMIT 10"},
+		},
+		{name: "unknown order", query: "order=secret", wantErr: true, wantParam: "order"},
+		{name: "malformed cursor", query: "cursor=%21%21", wantErr: true, wantParam: "cursor"},
+		{name: "cursor of another order", query: "order=name&cursor=" + validCursor, wantErr: true, wantParam: "cursor"},
+		{name: "cursor with ranking", query: "ranking=true&order=-name&cursor=" + validCursor,
+			wantErr: true, wantParam: "cursor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ResourcesHandler{db: db}
+			c := newQueryContext(tt.query)
+			var err error
+			sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				var q *gorm.DB
+				q, err = h.handlePage(c, tx.Model(&pageRow{}), "page_rows", pageFields, 10, 20)
+				if err != nil {
+					return tx
+				}
+				return q.Find(&[]pageRow{})
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("handlePage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if fe, ok := err.(*FilterError); !ok || fe.Param != tt.wantParam {
+					t.Errorf("handlePage() error = %#v, want FilterError on %s", err, tt.wantParam)
+				}
+				return
+			}
+			for _, part := range tt.want {
+				if !strings.Contains(sql, part) {
+					t.Errorf("handlePage() sql = %s, want to contain %s", sql, part)
+				}
+			}
+			if strings.Contains(tt.query, "cursor=") && strings.Contains(sql, "OFFSET") {
+				t.Errorf("handlePage() sql = %s, offset must be ignored with cursor", sql)
+			}
+		})
+	}
+}
+
+func TestSetNextCursor(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := []pageRow{
+		{ID: "a", Name: "first"},
+		{ID: "b", Name: "second", DateCreated: &created},
+	}
+
+	tests := []struct {
+		name       string
+		order      string
+		limit      int
+		rows       []pageRow
+		wantValues []interface{}
+	}{
+		{name: "page not full", order: "name", limit: 3, rows: rows},
+		{name: "no limit", order: "name", limit: 0, rows: rows},
+		{name: "name", order: "-name", limit: 2, rows: rows, wantValues: []interface{}{"second", "b"}},
+		{name: "time", order: "date_created", limit: 2, rows: rows,
+			wantValues: []interface{}{"2024-01-02T03:04:05Z", "b"}},
+		{name: "null value", order: "date_created", limit: 1, rows: rows[:1], wantValues: []interface{}{nil, "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, err := parseOrder(tt.order, pageFields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := &ResourcesHandler{db: newDryRunDB(t), pageOrders: orders, pageLimit: tt.limit}
+			h.setNextCursor(&tt.rows)
+			if tt.wantValues == nil {
+				if h.nextCursor != "" {
+					t.Errorf("setNextCursor() = %q, want no cursor", h.nextCursor)
+				}
+				return
+			}
+
+			token, err := decodeCursor(h.nextCursor)
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			if token.Order != orderString(orders) {
+				t.Errorf("cursor order = %q, want %q", token.Order, orderString(orders))
+			}
+			if !reflect.DeepEqual(token.Values, tt.wantValues) {
+				t.Errorf("cursor values = %#v, want %#v", token.Values, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestCountPage(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		wantCount   int64
+		wantSkip    bool
+		wantQueries int
+	}{
+		{name: "first page", query: "limit=10", wantCount: 42, wantQueries: 1},
+		{name: "offset page", query: "limit=10&offset=10", wantCount: 42, wantQueries: 1},
+		{name: "cursor page", query: "limit=10&cursor=abc", wantSkip: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queries []string
+			db := newCountDB(t, 42, &queries)
+			h := &ResourcesHandler{db: db}
+			count, err := h.countPage(newQueryContext(tt.query), db.Model(&pageRow{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.wantCount || h.skipCount != tt.wantSkip {
+				t.Errorf("countPage() = %d, skip %v, want %d, skip %v", count, h.skipCount, tt.wantCount, tt.wantSkip)
+			}
+			if len(queries) != tt.wantQueries {
+				t.Errorf("countPage() ran %d count queries, want %d", len(queries), tt.wantQueries)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package pkg
+
+import (
+	"middleman/pkg/middleware"
+	"middleman/pkg/middleware/models"
+)
+
+var (
+	allRoles   = []models.RoleType{models.RoleMaster, models.RoleSlave}
+	masterOnly = []models.RoleType{models.RoleMaster}
+)
+
+// 分节点只能操作自身数据库，跨分节点的操作只允许主节点调用，
+// 节点和密钥的管理只能使用节点自身的密钥，避免受限的 API key 提升自身权限
+var routePolicy = middleware.Policy{
+	"GET /middleman/slave-nodes/":                    {Roles: masterOnly, NodeKeyOnly: true},
+	"GET /middleman/slave-nodes/:name/":              {Roles: masterOnly, NodeKeyOnly: true},
+	"PATCH /middleman/slave-nodes/:name/":            {Roles: masterOnly, NodeKeyOnly: true},
+	"DELETE /middleman/slave-nodes/:name/":           {Roles: masterOnly, NodeKeyOnly: true},
+	"POST /middleman/slave-nodes/:name/rotate-keys/": {Roles: masterOnly, NodeKeyOnly: true},
+
+	"POST /middleman/keys/rotate/":    {Roles: allRoles, NodeKeyOnly: true},
+	"GET /middleman/api-keys/":        {Roles: allRoles, NodeKeyOnly: true},
+	"POST /middleman/api-keys/":       {Roles: allRoles, NodeKeyOnly: true},
+	"DELETE /middleman/api-keys/:id/": {Roles: allRoles, NodeKeyOnly: true},
+
+	"GET /middleman/enrollment-tokens/":        {Roles: masterOnly, NodeKeyOnly: true},
+	"POST /middleman/enrollment-tokens/":       {Roles: masterOnly, NodeKeyOnly: true},
+	"DELETE /middleman/enrollment-tokens/:id/": {Roles: masterOnly, NodeKeyOnly: true},
+
+	"GET /middleman/secret-reveal-logs/": {Roles: masterOnly, NodeKeyOnly: true},
+
+	"GET /middleman/resources/":            {Roles: allRoles},
+	"GET /middleman/resources/:id/":        {Roles: allRoles},
+	"GET /middleman/resources/:id/secret/": {Roles: masterOnly},
+	"GET /middleman/resources/export/":     {Roles: allRoles},
+	"POST /middleman/resources/":           {Roles: allRoles},
+	"POST /middleman/resources/import/":    {Roles: allRoles},
+	"PATCH /middleman/resources/:id/": {
+		Roles: allRoles,
+		// 启用、禁用用户与 PATCH user 的 is_active 及角色绑定的增删一样，只修改分节点自身的数据库
+		MTypes: map[string][]models.RoleType{
+			UserUnblock:  masterOnly,
+			UserResetMFA: masterOnly,
+		},
+	},
+	"DELETE /middleman/resources/:id/": {Roles: allRoles},
+}
@@ -0,0 +1,65 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+
+	"middleman/pkg/middleware"
+	"middleman/pkg/middleware/models"
+)
+
+// 每个需要认证的路由都要声明策略，未声明的路由会被一律拒绝
+func TestRoutePolicyCoversRoutes(t *testing.T) {
+	routes := map[string]bool{}
+	for _, route := range NewHttpServer().router.Routes() {
+		if !strings.HasPrefix(route.Path, "/middleman/") {
+			continue
+		}
+		key := middleware.PolicyKey(route.Method, route.Path)
+		routes[key] = true
+		if _, exists := routePolicy[key]; !exists {
+			t.Errorf("route %s has no policy", key)
+		}
+	}
+	for key := range routePolicy {
+		if !routes[key] {
+			t.Errorf("policy %s does not match any route", key)
+		}
+	}
+}
+
+// 节点和密钥的管理路由不接受 API key
+func TestRoutePolicyNodeKeyOnly(t *testing.T) {
+	for key, rule := range routePolicy {
+		path := strings.SplitN(key, " ", 2)[1]
+		if strings.HasPrefix(path, "/middleman/resources/") {
+			if rule.NodeKeyOnly {
+				t.Errorf("resource route %s should accept scoped API keys", key)
+			}
+			continue
+		}
+		if !rule.NodeKeyOnly {
+			t.Errorf("management route %s accepts API keys", key)
+		}
+	}
+}
+
+// 修改用户状态及角色绑定的各个入口对分节点的限制一致
+func TestRoutePolicyUserWrites(t *testing.T) {
+	tests := []struct {
+		key   string
+		mType string
+	}{
+		{key: "PATCH /middleman/resources/:id/", mType: User},
+		{key: "PATCH /middleman/resources/:id/", mType: UserActivate},
+		{key: "PATCH /middleman/resources/:id/", mType: UserDeactivate},
+		{key: "DELETE /middleman/resources/:id/", mType: User},
+		{key: "POST /middleman/resources/", mType: RoleBinding},
+		{key: "DELETE /middleman/resources/:id/", mType: RoleBinding},
+	}
+	for _, tt := range tests {
+		if !routePolicy[tt.key].Allows(models.RoleSlave, tt.mType) {
+			t.Errorf("%s m_type=%s is not allowed for slaves", tt.key, tt.mType)
+		}
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"time"
 
 	"middleman/pkg/config"
+	"middleman/pkg/database"
+	"middleman/pkg/health"
 	"middleman/pkg/middleware"
 	"middleman/pkg/utils"
 
@@ -30,13 +32,30 @@ func NewHttpServer() *HttpServer {
 	r.POST("register/", handleRegister)
 
 	g := r.Group("middleman/")
-	g.Use(middleware.AccessKeyMiddleware())
+	g.Use(middleware.AccessKeyMiddleware(), middleware.PolicyMiddleware(routePolicy))
 	g.GET("slave-nodes/", getSlaveNodes)
+	g.GET("slave-nodes/:name/", getSlaveNode)
+	g.PATCH("slave-nodes/:name/", updateSlaveNode)
+	g.DELETE("slave-nodes/:name/", deregisterSlaveNode)
+	g.POST("slave-nodes/:name/rotate-keys/", rotateSlaveKeys)
+	g.POST("keys/rotate/", rotateKeys)
+	g.GET("api-keys/", getAPIKeys)
+	g.POST("api-keys/", createAPIKey)
+	g.DELETE("api-keys/:id/", deleteAPIKey)
+	g.GET("enrollment-tokens/", getEnrollmentTokens)
+	g.POST("enrollment-tokens/", createEnrollmentToken)
+	g.DELETE("enrollment-tokens/:id/", revokeEnrollmentToken)
+	g.GET("secret-reveal-logs/", getSecretRevealLogs)
 
-	g.DELETE("resources/:id/", deleteResource)
+	// 删除时自行查找资源所在的分节点，组织只用于限定删除的范围
+	g.DELETE("resources/:id/", middleware.OrgMiddleware(), deleteResource)
 
-	g.Use(middleware.DatabaseMiddleware())
+	g.Use(middleware.DatabaseMiddleware(), middleware.OrgMiddleware())
 	g.GET("resources/", getResources)
+	g.GET("resources/export/", exportResources)
+	g.POST("resources/import/", importResources)
+	g.GET("resources/:id/", getResource)
+	g.GET("resources/:id/secret/", revealAccountSecret)
 	g.POST("resources/", saveResources)
 	g.PATCH("resources/:id/", updateResources)
 
@@ -62,10 +81,14 @@ func (s *HttpServer) Stop() {
 }
 
 func RunForever() {
+	// 启动时连接数据库，连接失败直接退出
+	database.GetDBManager()
+
 	cancelCtx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	retryManger := utils.GetRetryer()
 	retryManger.Start(cancelCtx)
+	health.NewProber().Start(cancelCtx)
 
 	httpServer := NewHttpServer()
 	go func() {
@@ -0,0 +1,316 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"middleman/pkg/consts"
+	"middleman/pkg/database"
+	"middleman/pkg/database/models"
+	mm "middleman/pkg/middleware/models"
+	"middleman/pkg/utils"
+)
+
+var accountSecretTypes = map[string]bool{
+	"password": true, "ssh_key": true, "access_key": true, "token": true, "api_key": true,
+}
+
+// accountRequest is_active 未传时默认为 true
+type accountRequest struct {
+	models.Account
+
+	IsActive *bool `json:"is_active"`
+}
+
+func (r *accountRequest) toAccount() models.Account {
+	account := r.Account
+	account.IsActive = r.IsActive == nil || *r.IsActive
+	return account
+}
+
+func operatorName(c *gin.Context) string {
+	authServer := c.MustGet(consts.AuthDBInfoContextKey).(mm.JumpServer)
+	return string(authServer.Name)
+}
+
+func (h *ResourcesHandler) saveAccount(c *gin.Context) (ids []string, err error) {
+	var reqs []accountRequest
+	if err = c.ShouldBindJSON(&reqs); err != nil {
+		return nil, err
+	}
+
+	operator := operatorName(c)
+	accounts := make([]models.Account, 0, len(reqs))
+	for _, req := range reqs {
+		account := req.toAccount()
+		if account.AssetID == "" || account.Username == "" {
+			return nil, newStatusError(http.StatusBadRequest, "asset_id and username are required")
+		}
+		if account.ID == "" {
+			account.ID = uuid.New().String()
+		}
+		if account.Name == "" {
+			account.Name = account.Username
+		}
+		if account.SecretType == "" {
+			account.SecretType = "password"
+		}
+		if !accountSecretTypes[account.SecretType] {
+			return nil, newStatusError(http.StatusBadRequest, "invalid secret_type: %s", account.SecretType)
+		}
+		if account.Source == "" {
+			account.Source = "local"
+		}
+		if account.CreatedBy == "" {
+			account.CreatedBy = operator
+		}
+		account.UpdatedBy = operator
+		account.Connectivity = "-"
+		if account.Secret != "" {
+			if account.MMSecret, err = utils.EncryptString(account.Secret); err != nil {
+				return nil, err
+			}
+			account.Version = 1
+		}
+		accounts = append(accounts, account)
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		for i := range accounts {
+			account := &accounts[i]
+			var asset models.Asset
+			txErr := tx.Select("id", "org_id").Where("id = ? AND org_id = ?", account.AssetID, h.orgID).
+				First(&asset).Error
+			if errors.Is(txErr, gorm.ErrRecordNotFound) {
+				return newStatusError(http.StatusBadRequest, "asset %s not found", account.AssetID)
+			}
+			if txErr != nil {
+				return fmt.Errorf("asset %s: %w", account.AssetID, txErr)
+			}
+			account.OrgID = asset.OrgID
+
+			var count int64
+			if txErr = tx.Model(models.Account{}).
+				Where("id = ? OR (name = ? AND asset_id = ?)", account.ID, account.Name, account.AssetID).
+				Count(&count).Error; txErr != nil {
+				return txErr
+			}
+			if count > 0 {
+				return newStatusError(http.StatusConflict,
+					"account %s already exists on asset %s", account.Name, account.AssetID)
+			}
+			if txErr = tx.Omit("Asset").Create(account).Error; txErr != nil {
+				return txErr
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, account := range accounts {
+		ids = append(ids, account.ID)
+		go func(account models.Account) {
+			h.jmsClient.CreateAccount(account.ToJms())
+			if account.PushNow {
+				h.jmsClient.PushAccounts([]string{account.ID})
+			}
+		}(account)
+	}
+	return ids, nil
+}
+
+// updateAccount 只更新请求体中出现的字段，secret 出现时重新加密并递增版本
+func (h *ResourcesHandler) updateAccount(c *gin.Context, id string) (err error) {
+	body, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+	var keys map[string]json.RawMessage
+	if err = json.Unmarshal(body, &keys); err != nil {
+		return err
+	}
+	var req accountRequest
+	if err = json.Unmarshal(body, &req); err != nil {
+		return err
+	}
+	account := req.toAccount()
+	if _, exists := keys["secret_type"]; exists && !accountSecretTypes[account.SecretType] {
+		return newStatusError(http.StatusBadRequest, "invalid secret_type: %s", account.SecretType)
+	}
+
+	columns, err := h.updateColumns(&models.Account{}, keys)
+	if err != nil {
+		return err
+	}
+	account.UpdatedBy = operatorName(c)
+	columns = append(columns, "updated_by")
+
+	_, secretChanged := keys["secret"]
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var current models.Account
+		if txErr := tx.Select("id").Where("id = ? AND org_id = ?", id, h.orgID).
+			First(&current).Error; txErr != nil {
+			return txErr
+		}
+		if txErr := tx.Model(&models.Account{}).Where("id = ?", id).
+			Select(columns).Updates(&account).Error; txErr != nil {
+			return txErr
+		}
+		if !secretChanged {
+			return nil
+		}
+		ciphertext, txErr := utils.EncryptString(account.Secret)
+		if txErr != nil {
+			return txErr
+		}
+		return tx.Model(&models.Account{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+			"mm_secret": ciphertext, "version": gorm.Expr("version + 1"),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	var updated models.Account
+	if err = h.db.Where("id = ?", id).First(&updated).Error; err != nil {
+		return err
+	}
+	if secretChanged {
+		updated.Secret = account.Secret
+	}
+	go func() {
+		h.jmsClient.UpdateAccount(updated.ToJms())
+		if account.PushNow {
+			h.jmsClient.PushAccounts([]string{id})
+		}
+	}()
+	return nil
+}
+
+func (h *ResourcesHandler) deleteAccount(id, cacheKey string) (err error) {
+	result := h.db.Where("id = ? AND org_id = ?", id, h.orgID).Delete(&models.Account{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errResourceNotFound
+	}
+	go h.jmsClient.RemoveAccount(id, cacheKey)
+	return nil
+}
+
+// revealAccountSecret 解密并返回账号密文，每次查看都会记录到默认库中
+func revealAccountSecret(c *gin.Context) {
+	if c.Query("m_type") != Account {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request type",
+			"details": fmt.Sprintf("Invalid request type: %s", c.Query("m_type")),
+		})
+		return
+	}
+	dbInfo, exists := c.Get(consts.DBInfoContextKey)
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid branch node name",
+			"details": "Secret reveal must target exactly one slave node",
+		})
+		return
+	}
+	server := dbInfo.(mm.JumpServer)
+	handler, err := newResourcesHandler(c, server)
+	if err != nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "Database init failed", "details": "Database init failed",
+		})
+		return
+	}
+
+	var account models.Account
+	err = handler.db.Select("id", "asset_id", "username", "secret_type", "version", "mm_secret").
+		Where("id = ? AND org_id = ?", c.Param("id"), handler.orgID).First(&account).Error
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": "Account not found", "details": err.Error()})
+		return
+	}
+	if account.MMSecret == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Secret not found", "details": "Account secret is not stored in middleman",
+		})
+		return
+	}
+	secret, err := utils.DecryptString(account.MMSecret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Decrypt secret failed", "details": err.Error()})
+		return
+	}
+
+	// 审计记录写入失败时不返回密文
+	revealLog := mm.SecretRevealLog{
+		SlaveName: server.Name, AccountID: account.ID, AssetID: account.AssetID,
+		Username: account.Username, RevealedBy: operatorName(c), RemoteIP: c.ClientIP(),
+		AccessKey: c.GetString(consts.AuthAccessKeyContextKey),
+	}
+	if value, exists := c.Get(consts.APIKeyContextKey); exists {
+		apiKey := value.(mm.APIKey)
+		revealLog.APIKeyID = &apiKey.ID
+	}
+	if err = database.GetDBManager().GetDefaultDB().Create(&revealLog).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"id": account.ID, "secret_type": account.SecretType,
+		"version": account.Version, "secret": secret,
+	}})
+}
+
+func getSecretRevealLogs(c *gin.Context) {
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "15"))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid param limit",
+			"details": "Param limit must be between 1 and 200",
+		})
+		return
+	}
+	db := database.GetDBManager().GetDefaultDB()
+	q := db.Model(&mm.SecretRevealLog{})
+	if name := c.Query("slave_name"); name != "" {
+		q = q.Where("slave_name = ?", name)
+	}
+	if accountID := c.Query("account_id"); accountID != "" {
+		q = q.Where("account_id = ?", accountID)
+	}
+	if accessKey := c.Query("access_key"); accessKey != "" {
+		q = q.Where("access_key = ?", accessKey)
+	}
+
+	var count int64
+	if err = q.Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+		return
+	}
+	var logs []mm.SecretRevealLog
+	if err = q.Order("id DESC").Offset(offset).Limit(limit).Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": logs, "total": count})
+}
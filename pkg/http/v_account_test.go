@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"middleman/pkg/consts"
+	"middleman/pkg/database/models"
+	mm "middleman/pkg/middleware/models"
+)
+
+func newAccountContext(method, target, body string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set(consts.AuthDBInfoContextKey, mm.JumpServer{
+		BaseJumpServer: mm.BaseJumpServer{Name: "master", Role: mm.RoleMaster},
+	})
+	return c, w
+}
+
+func TestAccountRequestToAccount(t *testing.T) {
+	active, inactive := true, false
+	tests := []struct {
+		name     string
+		isActive *bool
+		want     bool
+	}{
+		{name: "default", want: true},
+		{name: "active", isActive: &active, want: true},
+		{name: "inactive", isActive: &inactive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := accountRequest{IsActive: tt.isActive}
+			if got := req.toAccount().IsActive; got != tt.want {
+				t.Errorf("toAccount().IsActive = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 校验失败的请求不会访问数据库
+func TestSaveAccountValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "not a list", body: `{"asset_id":"1","username":"root"}`},
+		{name: "missing asset", body: `[{"username":"root"}]`, wantStatus: http.StatusBadRequest},
+		{name: "missing username", body: `[{"asset_id":"1"}]`, wantStatus: http.StatusBadRequest},
+		{name: "invalid secret type", body: `[{"asset_id":"1","username":"root","secret_type":"cookie"}]`,
+			wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newAccountContext(http.MethodPost, "/middleman/resources/?m_type=account", tt.body)
+			_, err := (&ResourcesHandler{}).saveAccount(c)
+			if err == nil {
+				t.Fatal("saveAccount() expected an error")
+			}
+			if status, ok := statusOf(err); tt.wantStatus != 0 && (!ok || status != tt.wantStatus) {
+				t.Errorf("saveAccount() status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRevealAccountSecretRequiresOneSlave(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{name: "other m_type", target: "/middleman/resources/1/secret/?m_type=user"},
+		{name: "no slave node", target: "/middleman/resources/1/secret/?m_type=account"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newAccountContext(http.MethodGet, tt.target, "")
+			revealAccountSecret(c)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("revealAccountSecret() status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+// 密文和资产关联不会随账号下发到 JumpServer 或返回给调用方
+func TestAccountToJms(t *testing.T) {
+	account := models.Account{
+		ID: "1", OrgID: "org", AssetID: "asset", SuFromID: "su", Username: "root",
+		MMSecret: "ciphertext", Secret: "secret", PushNow: true,
+		Asset: models.Asset{ID: "asset"},
+	}
+	data, err := json.Marshal(account.ToJms())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["asset"] != "asset" || got["su_from"] != "su" || got["secret"] != "secret" {
+		t.Errorf("ToJms() = %s, want asset, su_from and secret", data)
+	}
+	if strings.Contains(string(data), "ciphertext") || got["push_now"] == true || got["org_id"] != "" {
+		t.Errorf("ToJms() = %s, leaks ciphertext, push_now or org_id", data)
+	}
+}
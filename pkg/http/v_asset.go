@@ -1,11 +1,13 @@
 package pkg
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"middleman/pkg/database/models"
+	"middleman/pkg/utils"
 )
 
 func (h *ResourcesHandler) savePlatform(c *gin.Context) (err error) {
@@ -33,215 +35,516 @@ func (h *ResourcesHandler) savePlatform(c *gin.Context) (err error) {
 	return nil
 }
 
-func (h *ResourcesHandler) saveHost(c *gin.Context) (ids []string, err error) {
-	var hosts []models.Host
-	if err = c.ShouldBindJSON(&hosts); err != nil {
+// bindTypedAssets 按类别解析请求中的资产列表
+func bindTypedAssets(c *gin.Context, category string) ([]models.TypedAsset, error) {
+	var raws []json.RawMessage
+	if err := c.ShouldBindJSON(&raws); err != nil {
 		return nil, err
 	}
-	for _, host := range hosts {
+	assets := make([]models.TypedAsset, 0, len(raws))
+	for _, raw := range raws {
+		asset, err := models.NewTypedAsset(category)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(raw, asset); err != nil {
+			return nil, err
+		}
+		if v, ok := asset.(interface{ Validate() error }); ok {
+			if err = v.Validate(); err != nil {
+				return nil, err
+			}
+		}
+		assets = append(assets, asset)
+	}
+	return assets, nil
+}
+
+// checkAssetPlatform 资产的平台类别需要与资产类别一致
+func (h *ResourcesHandler) checkAssetPlatform(tx *gorm.DB, platformID uint, category string) error {
+	var count int64
+	if err := tx.Model(models.Platform{}).
+		Where("id = ? AND category = ?", platformID, category).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("platform %d is not a %s platform", platformID, category)
+	}
+	return nil
+}
+
+func (h *ResourcesHandler) saveAsset(c *gin.Context, category string) (ids []string, err error) {
+	assets, err := bindTypedAssets(c, category)
+	if err != nil {
+		return nil, err
+	}
+	for _, typedAsset := range assets {
+		asset := typedAsset.GetAsset()
+		asset.OrgID = h.orgID
+		if err = h.checkResourceOrg(models.Asset{}, Asset, asset.ID); err != nil {
+			return nil, err
+		}
 		var count int64
-		if err = h.db.Model(host).Where("asset_ptr_id = ?", host.Asset.ID).
-			Count(&count).Error; err != nil {
+		if err = h.orgScope(h.db.Model(typedAsset).
+			Joins("JOIN assets ON assets.id = asset_ptr_id"), "assets").
+			Where("asset_ptr_id = ?", asset.ID).Count(&count).Error; err != nil {
 			return nil, err
 		}
 
 		var newAccounts []models.Account
-		for _, account := range host.Asset.Accounts {
+		for _, account := range asset.Accounts {
 			if account.ID == "" {
 				account.ID = uuid.New().String()
 			}
 			account.Connectivity = "-"
-			account.OrgID = models.DefaultOrgID
+			account.OrgID = h.orgID
+			if account.Secret != "" {
+				if account.MMSecret, err = utils.EncryptString(account.Secret); err != nil {
+					return nil, err
+				}
+			}
 			newAccounts = append(newAccounts, account)
 		}
-		host.Asset.Accounts = newAccounts
+		asset.Accounts = newAccounts
 
 		if count > 0 {
-			if err = h.db.Model(host).Omit("id").Updates(&host).Error; err != nil {
+			if err = h.db.Model(typedAsset).Omit("id").Updates(typedAsset).Error; err != nil {
 				return nil, err
 			}
 		} else {
 			err = h.db.Transaction(func(tx *gorm.DB) error {
 				var txErr error
+				if txErr = h.checkAssetPlatform(tx, asset.PlatformID, category); txErr != nil {
+					return txErr
+				}
 
 				var nameCount int64
 				if txErr = tx.Model(models.Asset{}).
-					Where("name = ? AND org_id = ?", host.Asset.Name, host.Asset.OrgID).
+					Where("name = ? AND org_id = ?", asset.Name, asset.OrgID).
 					Limit(1).Count(&nameCount).Error; txErr != nil {
 					return txErr
 				}
 
 				if nameCount > 0 {
-					return fmt.Errorf("name %s already exists", host.Asset.Name)
+					return fmt.Errorf("name %s already exists", asset.Name)
 				}
 
-				if txErr = tx.Create(&host).Error; txErr != nil {
+				if txErr = tx.Create(typedAsset).Error; txErr != nil {
 					return txErr
 				}
-
-				type Relation struct {
-					AssetID string `gorm:"column:asset_id"`
-					NodeID  string `gorm:"column:node_id"`
-				}
-				var relations []Relation
-				for _, nodeID := range host.Asset.NodeIds {
-					relations = append(relations, Relation{
-						NodeID: nodeID, AssetID: host.AssetPtrID,
-					})
-				}
-				if txErr = tx.Table("assets_asset_nodes").
-					CreateInBatches(relations, 100).Error; txErr != nil {
-					return txErr
-				}
-				return nil
+				return h.setAssetNodes(tx, asset.ID, asset.NodeIds)
 			})
 			if err != nil {
 				return nil, err
 			}
 
-			ids = append(ids, host.AssetPtrID)
-			go h.jmsClient.CreateAsset(host)
+			ids = append(ids, asset.ID)
+			go h.jmsClient.CreateAsset(typedAsset)
 		}
 	}
 
 	return ids, nil
 }
 
-func (h *ResourcesHandler) getPlatforms(c *gin.Context, limit, offset int) (interface{}, int64, error) {
-	var err error
-	var platforms []models.Platform
-	queryFields := map[string]bool{
-		"name":     true,
-		"type":     true,
-		"category": true,
+// setAssetNodes 使用 nodeIds 替换资产所在的节点
+func (h *ResourcesHandler) setAssetNodes(tx *gorm.DB, assetID string, nodeIds []string) error {
+	type Relation struct {
+		AssetID string `gorm:"column:asset_id"`
+		NodeID  string `gorm:"column:node_id"`
 	}
-	q := h.db.Model(&models.Platform{})
-	for key, values := range c.Request.URL.Query() {
-		if h.processedParams[key] || !queryFields[key] {
-			continue
+	if err := tx.Table("assets_asset_nodes").
+		Where("asset_id = ?", assetID).Delete(nil).Error; err != nil {
+		return err
+	}
+	if len(nodeIds) == 0 {
+		return nil
+	}
+	var relations []Relation
+	for _, nodeID := range nodeIds {
+		relations = append(relations, Relation{NodeID: nodeID, AssetID: assetID})
+	}
+	return tx.Table("assets_asset_nodes").CreateInBatches(relations, 100).Error
+}
+
+// updateColumns 返回请求体中出现且属于 model 的数据库字段
+func (h *ResourcesHandler) updateColumns(model interface{}, keys map[string]json.RawMessage) ([]string, error) {
+	stmt := &gorm.Statement{DB: h.db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	var columns []string
+	for key := range keys {
+		if field := stmt.Schema.LookUpField(key); field != nil && field.DBName != "" &&
+			field.Updatable && !field.PrimaryKey && !readonlyColumns[field.DBName] {
+			columns = append(columns, field.DBName)
 		}
+	}
+	return columns, nil
+}
+
+// readonlyColumns 不允许通过更新接口修改的字段
+var readonlyColumns = map[string]bool{
+	"org_id": true, "connectivity": true, "created_by": true,
+	"date_created": true, "date_verified": true,
+	"asset_id": true, "version": true, "mm_secret": true,
+}
 
-		if len(values) > 0 {
-			q = q.Where(fmt.Sprintf("%s = ?", key), values[len(values)-1])
+// updateAsset 只更新请求体中出现的字段，nodes 出现时替换资产所在的节点
+func (h *ResourcesHandler) updateAsset(c *gin.Context, category, id string) (err error) {
+	body, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+	var keys map[string]json.RawMessage
+	if err = json.Unmarshal(body, &keys); err != nil {
+		return err
+	}
+	typedAsset, err := models.NewTypedAsset(category)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(body, typedAsset); err != nil {
+		return err
+	}
+
+	var current models.Asset
+	if err = h.orgScope(h.db.Preload("Platform"), "assets").Where("id = ?", id).
+		First(&current).Error; err != nil {
+		return err
+	}
+	if current.Platform.Category != category {
+		return fmt.Errorf("asset %s is not a %s", id, category)
+	}
+
+	asset := typedAsset.GetAsset()
+	if _, exists := keys["platform_id"]; !exists {
+		asset.PlatformID = current.PlatformID
+	}
+	if v, ok := typedAsset.(interface{ Validate() error }); ok {
+		if err = v.Validate(); err != nil {
+			return err
+		}
+	}
+
+	assetColumns, err := h.updateColumns(&models.Asset{}, keys)
+	if err != nil {
+		return err
+	}
+	specColumns, err := h.updateColumns(typedAsset, keys)
+	if err != nil {
+		return err
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if _, exists := keys["platform_id"]; exists {
+			if txErr := h.checkAssetPlatform(tx, asset.PlatformID, category); txErr != nil {
+				return txErr
+			}
 		}
+		if len(assetColumns) > 0 {
+			if txErr := tx.Model(&models.Asset{}).Where("id = ?", id).
+				Select(assetColumns).Updates(asset).Error; txErr != nil {
+				return txErr
+			}
+		}
+		if len(specColumns) > 0 {
+			if txErr := tx.Model(typedAsset).Where("asset_ptr_id = ?", id).
+				Select(specColumns).Updates(typedAsset).Error; txErr != nil {
+				return txErr
+			}
+		}
+		if _, exists := keys["nodes"]; exists {
+			return h.setAssetNodes(tx, id, asset.NodeIds)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	searchFields := []string{"name", "type", "category"}
+	// 重新读取完整的资产后再推送，避免 JumpServer 中缺失未修改的字段
+	updated, err := models.NewTypedAsset(category)
+	if err != nil {
+		return err
+	}
+	if err = h.db.Preload("Asset").Preload("Asset.Nodes").
+		Where("asset_ptr_id = ?", id).First(updated).Error; err != nil {
+		return err
+	}
+	asset = updated.GetAsset()
+	for _, node := range asset.Nodes {
+		asset.NodeIds = append(asset.NodeIds, node.ID)
+	}
+	go h.jmsClient.UpdateAsset(updated)
+	return nil
+}
+
+var platformFilterFields = FilterFields{
+	"id":           NumberField,
+	"name":         TextField,
+	"type":         TextField,
+	"category":     TextField,
+	"date_created": TimeField,
+}
+
+func (h *ResourcesHandler) platformQuery(c *gin.Context) (*gorm.DB, error) {
+	var err error
+	q := h.db.Model(&models.Platform{})
+	if q, err = h.handleFilter(c, q, "platforms", platformFilterFields); err != nil {
+		return nil, err
+	}
+
+	searchFields := []string{"platforms.name", "platforms.type", "platforms.category"}
 	q = h.handleSearch(c, q, searchFields)
+	return q, nil
+}
 
-	var count int64
-	if err = q.Count(&count).Limit(limit).Offset(offset).Find(&platforms).Error; err != nil {
+func (h *ResourcesHandler) getPlatforms(c *gin.Context, limit, offset int) (interface{}, int64, error) {
+	var platforms []models.Platform
+	q, err := h.platformQuery(c)
+	if err != nil {
 		return nil, 0, err
 	}
+
+	count, err := h.countPage(c, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	if q, err = h.handleFields(c, q, "platforms", &models.Platform{}, []string{"id"}); err != nil {
+		return nil, 0, err
+	}
+	if q, err = h.handlePage(c, q, "platforms", platformFilterFields, limit, offset); err != nil {
+		return nil, 0, err
+	}
+	if err = q.Find(&platforms).Error; err != nil {
+		return nil, 0, err
+	}
+	h.setNextCursor(platforms)
 	return platforms, count, nil
 }
 
-func (h *ResourcesHandler) getAssets(c *gin.Context, limit, offset int, category string) (interface{}, int64, error) {
-	var err error
-	var assets []models.Asset
-	queryFields := map[string]bool{
-		"id":        true,
-		"address":   true,
-		"name":      true,
-		"is_active": true,
+type respAsset struct {
+	models.Asset
+
+	Nodes        []models.SimpleNode `json:"nodes"`
+	NodesDisplay []string            `json:"nodes_display"`
+}
+
+func newRespAsset(asset models.Asset) respAsset {
+	p := asset.Platform
+	asset.Category = models.LabelValue{Label: p.Category, Value: p.Category}
+	asset.Type = models.LabelValue{Label: p.Type, Value: p.Type}
+
+	nodesDisplay := make([]string, 0, len(asset.Nodes))
+	newNodes := make([]models.SimpleNode, 0, len(asset.Nodes))
+	for _, n := range asset.Nodes {
+		nodesDisplay = append(nodesDisplay, n.FullValue)
+		newNodes = append(newNodes, models.SimpleNode{ID: n.ID, Name: n.Value})
 	}
+	return respAsset{Asset: asset, NodesDisplay: nodesDisplay, Nodes: newNodes}
+}
 
+var assetFilterFields = FilterFields{
+	"id":           ExactField,
+	"address":      TextField,
+	"name":         TextField,
+	"is_active":    BoolField,
+	"platform_id":  NumberField,
+	"date_created": TimeField,
+	"date_updated": TimeField,
+}
+
+func (h *ResourcesHandler) assetQuery(c *gin.Context, category string) (*gorm.DB, error) {
+	var err error
 	nodeID := c.Query("node_id")
 	if nodeID == "" {
 		nodeID = c.Query("node_id")
 	}
 
-	q := h.db.Model(&models.Asset{}).Preload("Platform")
+	relations := Relations{
+		"platform": {Preload: "Platform", Keys: []string{"platform", "category", "type"}},
+		"nodes": {Preload: "Nodes", Keys: []string{"nodes", "nodes_display"}, Scope: func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "value", "full_value")
+		}},
+	}
+	q := h.orgScope(h.db.Model(&models.Asset{}), "assets")
+	if q, err = h.handleExpand(c, q, relations, []string{"platform", "nodes"}); err != nil {
+		return nil, err
+	}
 	if nodeID != "" {
 		q = q.Where("? IN (SELECT node_id FROM assets_asset_nodes WHERE asset_id = assets.id)", nodeID)
 	}
-	q.Preload("Nodes", func(db *gorm.DB) *gorm.DB {
-		return db.Select("id", "value", "full_value")
-	})
 
 	if category != "" {
 		q = q.Joins("JOIN platforms ON platforms.id = assets.platform_id").
 			Where("platforms.category = ?", category)
 	}
 
-	for key, values := range c.Request.URL.Query() {
-		if h.processedParams[key] || !queryFields[key] {
-			continue
-		}
-
-		if len(values) > 0 {
-			q = q.Where(fmt.Sprintf("%s = ?", key), values[len(values)-1])
-		}
+	if q, err = h.handleFilter(c, q, "assets", assetFilterFields); err != nil {
+		return nil, err
 	}
 
-	searchFields := []string{"address", "name"}
+	searchFields := []string{"assets.address", "assets.name"}
 	q = h.handleSearch(c, q, searchFields)
+	return q, nil
+}
 
-	var count int64
-	if err = q.Count(&count).Limit(limit).Offset(offset).Find(&assets).Error; err != nil {
+func (h *ResourcesHandler) getAssets(c *gin.Context, limit, offset int, category string) (interface{}, int64, error) {
+	var assets []models.Asset
+	q, err := h.assetQuery(c, category)
+	if err != nil {
 		return nil, 0, err
 	}
 
-	type respAsset struct {
-		models.Asset
-
-		Nodes        []models.SimpleNode `json:"nodes"`
-		NodesDisplay []string            `json:"nodes_display"`
+	count, err := h.countPage(c, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	if q, err = h.handleFields(c, q, "assets", &models.Asset{}, []string{"id", "platform_id"}); err != nil {
+		return nil, 0, err
+	}
+	if q, err = h.handlePage(c, q, "assets", assetFilterFields, limit, offset); err != nil {
+		return nil, 0, err
+	}
+	if err = q.Find(&assets).Error; err != nil {
+		return nil, 0, err
 	}
+	h.setNextCursor(assets)
+
 	newAssets := make([]respAsset, 0, len(assets))
 	for _, asset := range assets {
-		p := asset.Platform
-		asset.Category = models.LabelValue{Label: p.Category, Value: p.Category}
-		asset.Type = models.LabelValue{Label: p.Type, Value: p.Type}
 		asset.Accounts = nil
-
-		nodesDisplay := make([]string, 0, len(asset.Nodes))
-		newNodes := make([]models.SimpleNode, 0, len(asset.Nodes))
-		for _, n := range asset.Nodes {
-			nodesDisplay = append(nodesDisplay, n.FullValue)
-			newNodes = append(newNodes, models.SimpleNode{ID: n.ID, Name: n.Value})
-		}
-		newAssets = append(newAssets, respAsset{
-			Asset: asset, NodesDisplay: nodesDisplay, Nodes: newNodes,
-		})
+		newAssets = append(newAssets, newRespAsset(asset))
 	}
 	return newAssets, count, nil
 }
 
-func (h *ResourcesHandler) getAccounts(c *gin.Context, limit, offset int) (interface{}, int64, error) {
-	var err error
-	var accounts []models.Account
-	queryFields := map[string]bool{
-		"id":          true,
-		"name":        true,
-		"username":    true,
-		"secret_type": true,
-	}
-	q := h.db.Model(&models.Account{}).Preload("Asset")
-	for key, values := range c.Request.URL.Query() {
-		if h.processedParams[key] || !queryFields[key] {
-			continue
-		}
+var accountFilterFields = FilterFields{
+	"id":           ExactField,
+	"name":         TextField,
+	"username":     TextField,
+	"secret_type":  TextField,
+	"asset_id":     ExactField,
+	"privileged":   BoolField,
+	"is_active":    BoolField,
+	"date_created": TimeField,
+}
 
-		if len(values) > 0 {
-			q = q.Where(fmt.Sprintf("%s = ?", key), values[len(values)-1])
-		}
+func (h *ResourcesHandler) accountQuery(c *gin.Context) (*gorm.DB, error) {
+	var err error
+	relations := Relations{
+		"asset": {Preload: "Asset", Keys: []string{"asset"}},
+	}
+	q := h.orgScope(h.db.Model(&models.Account{}), "accounts")
+	if q, err = h.handleExpand(c, q, relations, []string{"asset"}); err != nil {
+		return nil, err
+	}
+	if q, err = h.handleFilter(c, q, "accounts", accountFilterFields); err != nil {
+		return nil, err
 	}
 
-	searchFields := []string{"username", "name"}
+	searchFields := []string{"accounts.username", "accounts.name"}
 	q = h.handleSearch(c, q, searchFields)
+	return q, nil
+}
 
-	var count int64
-	if err = q.Count(&count).Limit(limit).Offset(offset).Find(&accounts).Error; err != nil {
+func (h *ResourcesHandler) getAccounts(c *gin.Context, limit, offset int) (interface{}, int64, error) {
+	var accounts []models.Account
+	q, err := h.accountQuery(c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := h.countPage(c, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	if q, err = h.handleFields(c, q, "accounts", &models.Account{}, []string{"id", "asset_id"}); err != nil {
+		return nil, 0, err
+	}
+	if q, err = h.handlePage(c, q, "accounts", accountFilterFields, limit, offset); err != nil {
+		return nil, 0, err
+	}
+	if err = q.Find(&accounts).Error; err != nil {
 		return nil, 0, err
 	}
+	h.setNextCursor(accounts)
 	return accounts, count, nil
 }
 
-func (h *ResourcesHandler) deleteAsset(id, cacheKey string) (err error) {
-	err = h.db.Where("id = ?", id).Delete(&models.Asset{}).Error
+func (h *ResourcesHandler) getPlatform(id string) (interface{}, error) {
+	var platform models.Platform
+	if err := h.db.Where("id = ?", id).First(&platform).Error; err != nil {
+		return nil, err
+	}
+	return platform, nil
+}
+
+// assetSpec 返回资产类型对应子表中的字段
+func assetSpec(asset models.Asset) (map[string]interface{}, error) {
+	var sub models.TypedAsset
+	switch {
+	case asset.Host != nil:
+		sub = asset.Host
+	case asset.Web != nil:
+		sub = asset.Web
+	case asset.Device != nil:
+		sub = asset.Device
+	case asset.Database != nil:
+		sub = asset.Database
+	case asset.Cloud != nil:
+		sub = asset.Cloud
+	case asset.GPT != nil:
+		sub = asset.GPT
+	case asset.Custom != nil:
+		sub = asset.Custom
+	default:
+		return nil, nil
+	}
+	return models.AssetSpec(sub)
+}
+
+// getAsset category 不为空时，资产的平台类别需要与之一致
+func (h *ResourcesHandler) getAsset(id, category string) (interface{}, error) {
+	var asset models.Asset
+	q := h.db.Preload("Platform").Preload("Nodes").Preload("Accounts").
+		Preload("Host").Preload("Web").Preload("Device").Preload("Database").
+		Preload("Cloud").Preload("GPT").Preload("Custom")
+	if err := h.orgScope(q, "assets").Where("id = ?", id).First(&asset).Error; err != nil {
+		return nil, err
+	}
+	if category != "" && asset.Platform.Category != category {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	spec, err := assetSpec(asset)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return struct {
+		respAsset
+		Spec map[string]interface{} `json:"spec,omitempty"`
+	}{respAsset: newRespAsset(asset), Spec: spec}, nil
+}
+
+func (h *ResourcesHandler) getAccount(id string) (interface{}, error) {
+	var account models.Account
+	err := h.orgScope(h.db.Preload("Asset").Preload("Asset.Platform"), "accounts").
+		Where("id = ?", id).First(&account).Error
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (h *ResourcesHandler) deleteAsset(id, cacheKey string) (err error) {
+	result := h.db.Where("id = ? AND org_id = ?", id, h.orgID).Delete(&models.Asset{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errResourceNotFound
 	}
 	go h.jmsClient.RemoveAsset(id, cacheKey)
 	return nil
@@ -0,0 +1,301 @@
+package pkg
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"middleman/pkg/config"
+	"middleman/pkg/consts"
+	"middleman/pkg/database"
+	"middleman/pkg/middleware/models"
+	"middleman/pkg/utils"
+)
+
+type RotateKeyRequest struct {
+	// 旧密钥的宽限期（秒），为 0 时旧密钥立即失效
+	GracePeriod *int `json:"grace_period"`
+}
+
+func rotateKeys(c *gin.Context) {
+	if c.GetString(consts.AuthKeyContextKey) != models.KeyCurrent {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Permission denied",
+			"details": "Keys can only be rotated with the current key",
+		})
+		return
+	}
+	server := c.MustGet(consts.AuthDBInfoContextKey).(models.JumpServer)
+	rotateJumpServerKeys(c, server)
+}
+
+func rotateSlaveKeys(c *gin.Context) {
+	server, ok := findSlaveNode(c)
+	if !ok {
+		return
+	}
+	rotateJumpServerKeys(c, server)
+}
+
+func rotateJumpServerKeys(c *gin.Context, server models.JumpServer) {
+	var req RotateKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	gracePeriod := config.GetConf().KeyRotationGracePeriod
+	if req.GracePeriod != nil {
+		gracePeriod = *req.GracePeriod
+	}
+	if gracePeriod < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid param grace_period",
+			"details": "Param grace_period must not be negative",
+		})
+		return
+	}
+
+	accessKey := utils.GenerateRandomString(36)
+	secretKey := utils.GenerateRandomString(36)
+	encryptedSecret, err := server.Encrypt(secretKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	prevSecret, err := server.Encrypt(server.SecretKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	expiredAt := time.Now().Add(time.Duration(gracePeriod) * time.Second)
+
+	db := database.GetDBManager().GetDefaultDB()
+	result := db.Model(&models.JumpServer{}).
+		Where("id = ? AND access_key = ?", server.ID, server.AccessKey).
+		Updates(map[string]interface{}{
+			"access_key":          accessKey,
+			"secret_key":          encryptedSecret,
+			"prev_access_key":     server.AccessKey,
+			"prev_secret_key":     prevSecret,
+			"prev_key_expired_at": expiredAt,
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("轮换密钥失败: %v", result.Error),
+		})
+		return
+	}
+	// 并发轮换时只有一个请求能匹配到当前的 access key
+	if result.RowsAffected != 1 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Keys have been rotated by another request, please retry",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"name":                server.Name,
+			"access_key":          accessKey,
+			"secret_key":          secretKey,
+			"prev_access_key":     server.AccessKey,
+			"prev_key_expired_at": expiredAt,
+		},
+		"key_used": c.GetString(consts.AuthKeyContextKey),
+	})
+}
+
+var apiKeyMethods = map[string]bool{
+	http.MethodGet: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true,
+}
+
+type CreateAPIKeyRequest struct {
+	Name         string     `json:"name" binding:"required"`
+	Methods      []string   `json:"methods"`
+	MTypes       []string   `json:"m_types"`
+	RevealSecret bool       `json:"reveal_secret"`
+	ExpiredAt    *time.Time `json:"expired_at"`
+}
+
+func getAPIKeys(c *gin.Context) {
+	server := c.MustGet(consts.AuthDBInfoContextKey).(models.JumpServer)
+	db := database.GetDBManager().GetDefaultDB()
+	var keys []models.APIKey
+	if err := db.Where("jump_server_id = ?", server.ID).Order("id").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error", "details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": keys, "total": len(keys)})
+}
+
+func createAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var methods []string
+	for _, method := range req.Methods {
+		method = strings.ToUpper(method)
+		if !apiKeyMethods[method] {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid method: %s", method),
+			})
+			return
+		}
+		methods = append(methods, method)
+	}
+	for _, mType := range req.MTypes {
+		if !resourceTypes[mType] {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid m_type: %s", mType),
+			})
+			return
+		}
+	}
+	if req.ExpiredAt != nil && req.ExpiredAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expired_at must be in the future"})
+		return
+	}
+
+	server := c.MustGet(consts.AuthDBInfoContextKey).(models.JumpServer)
+	db := database.GetDBManager().GetDefaultDB()
+	var count int64
+	db.Model(&models.APIKey{}).
+		Where("jump_server_id = ? AND name = ?", server.ID, req.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("API key %s already exists", req.Name),
+		})
+		return
+	}
+
+	secretKey := utils.GenerateRandomString(36)
+	key := models.APIKey{
+		JumpServerID: server.ID,
+		Name:         req.Name,
+		AccessKey:    utils.GenerateRandomString(36),
+		SecretKey:    secretKey,
+		Methods:      methods,
+		MTypes:       req.MTypes,
+		RevealSecret: req.RevealSecret,
+		ExpiredAt:    req.ExpiredAt,
+	}
+	if err := db.Omit("JumpServer").Create(&key).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("创建 API key 失败: %v", err),
+		})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"data": key, "secret_key": secretKey,
+	})
+}
+
+func deleteAPIKey(c *gin.Context) {
+	server := c.MustGet(consts.AuthDBInfoContextKey).(models.JumpServer)
+	db := database.GetDBManager().GetDefaultDB()
+	result := db.Where("id = ? AND jump_server_id = ?", c.Param("id"), server.ID).
+		Delete(&models.APIKey{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error", "details": result.Error.Error(),
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key deleted successfully"})
+}
+
+type CreateEnrollmentTokenRequest struct {
+	NodeName  models.NameType `json:"node_name" binding:"required"`
+	Role      models.RoleType `json:"role" binding:"required"`
+	ExpiresIn int             `json:"expires_in"`
+}
+
+func getEnrollmentTokens(c *gin.Context) {
+	db := database.GetDBManager().GetDefaultDB()
+	q := db.Model(&models.EnrollmentToken{}).Order("id DESC")
+	if name := c.Query("node_name"); name != "" {
+		q = q.Where("node_name = ?", name)
+	}
+	var tokens []models.EnrollmentToken
+	if err := q.Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error", "details": err.Error(),
+		})
+		return
+	}
+	for i := range tokens {
+		tokens[i].Status = tokens[i].GetStatus()
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tokens, "total": len(tokens)})
+}
+
+func createEnrollmentToken(c *gin.Context) {
+	var req CreateEnrollmentTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	check := models.JumpServer{
+		BaseJumpServer: models.BaseJumpServer{Name: req.NodeName, Role: req.Role},
+	}
+	if err := check.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	expiresIn := config.GetConf().EnrollmentTokenTTL
+	if req.ExpiresIn > 0 {
+		expiresIn = req.ExpiresIn
+	}
+
+	authServer := c.MustGet(consts.AuthDBInfoContextKey).(models.JumpServer)
+	plaintext := utils.GenerateRandomString(48)
+	token := models.EnrollmentToken{
+		TokenHash: models.HashEnrollmentToken(plaintext),
+		NodeName:  req.NodeName,
+		Role:      req.Role,
+		ExpiredAt: time.Now().Add(time.Duration(expiresIn) * time.Second),
+		CreatedBy: string(authServer.Name),
+	}
+	db := database.GetDBManager().GetDefaultDB()
+	if err := db.Create(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("创建注册令牌失败: %v", err),
+		})
+		return
+	}
+	token.Status = token.GetStatus()
+	c.JSON(http.StatusCreated, gin.H{"data": token, "token": plaintext})
+}
+
+func revokeEnrollmentToken(c *gin.Context) {
+	db := database.GetDBManager().GetDefaultDB()
+	result := db.Model(&models.EnrollmentToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", c.Param("id")).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error", "details": result.Error.Error(),
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Enrollment token not found, used or revoked"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Enrollment token revoked successfully"})
+}
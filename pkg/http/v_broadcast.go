@@ -0,0 +1,209 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"middleman/pkg/consts"
+	"middleman/pkg/middleware/models"
+)
+
+// SlaveFailure 多节点写入时单个分节点的失败原因
+type SlaveFailure struct {
+	Slave models.NameType `json:"slave"`
+	Error string          `json:"error"`
+}
+
+// broadcastTargets 主节点使用名称列表、* 或标签选择器指定分节点时返回全部目标
+func broadcastTargets(c *gin.Context) ([]models.JumpServer, bool) {
+	value, exists := c.Get(consts.DBTargetsContextKey)
+	if !exists {
+		return nil, false
+	}
+	return value.([]models.JumpServer), true
+}
+
+// broadcast 依次在每个分节点上执行 fn，每次执行前重置请求体。
+// 全部成功返回 successStatus，部分失败返回 207，全部失败返回 500
+func broadcast(
+	c *gin.Context, targets []models.JumpServer, successStatus int,
+	fn func(handler *ResourcesHandler) error,
+) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	succeeded := make([]models.NameType, 0, len(targets))
+	failed := make([]SlaveFailure, 0)
+	for _, server := range targets {
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		handler, err := newResourcesHandler(c, server)
+		if err == nil {
+			err = fn(handler)
+		}
+		if err != nil {
+			failed = append(failed, SlaveFailure{Slave: server.Name, Error: err.Error()})
+			continue
+		}
+		succeeded = append(succeeded, server.Name)
+	}
+
+	status := successStatus
+	switch {
+	case len(failed) == len(targets):
+		status = http.StatusInternalServerError
+	case len(failed) > 0:
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, gin.H{
+		"message": fmt.Sprintf(
+			"Resource[%s] succeeded on %d/%d slave nodes",
+			c.Query("m_type"), len(succeeded), len(targets),
+		),
+		"succeeded": succeeded,
+		"failed":    failed,
+	})
+}
+
+// 支持跨分节点聚合查询的资源类型
+var aggregateResourceTypes = map[string]bool{
+	User:       true,
+	Permission: true,
+	Asset:      true,
+	Host:       true,
+	Web:        true,
+	Device:     true,
+	Database:   true,
+	Custom:     true,
+	Gpt:        true,
+	Cloud:      true,
+}
+
+func (h *ResourcesHandler) getAggregateResources(
+	c *gin.Context, resourceType string, limit, offset int,
+) (interface{}, int64, error) {
+	switch resourceType {
+	case User:
+		return h.getUsers(c, limit, offset)
+	case Permission:
+		return h.getPerms(c, limit, offset)
+	case Asset:
+		return h.getAssets(c, limit, offset, "")
+	default:
+		return h.getAssets(c, limit, offset, resourceType)
+	}
+}
+
+// annotateSlave 在每条记录中加入所属分节点的名称
+func annotateSlave(resources interface{}, slave models.NameType) ([]map[string]interface{}, error) {
+	data, err := json.Marshal(resources)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]interface{}
+	if err = json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		row["slave"] = slave
+	}
+	return rows, nil
+}
+
+// slavePageFetcher 查询单个分节点中 offset/limit 窗口内的记录及该节点的总数
+type slavePageFetcher func(server models.JumpServer, limit, offset int) ([]map[string]interface{}, int64, error)
+
+// collectSlavePages 按分节点顺序把多个分节点的数据视为一个整体进行分页。
+// 失败的分节点既不计入总数也不参与窗口换算，FilterError 直接返回
+func collectSlavePages(
+	targets []models.JumpServer, limit, offset int, fetch slavePageFetcher,
+) ([]map[string]interface{}, int64, []SlaveFailure, error) {
+	var total int64
+	results := make([]map[string]interface{}, 0, limit)
+	failed := make([]SlaveFailure, 0)
+	for _, server := range targets {
+		// 当前分节点之前已有 total 条记录，据此换算出在本节点内的窗口
+		localOffset := offset - int(total)
+		if localOffset < 0 {
+			localOffset = 0
+		}
+		rows, count, err := fetch(server, limit-len(results), localOffset)
+		var filterErr *FilterError
+		if errors.As(err, &filterErr) {
+			return nil, 0, nil, err
+		}
+		if err != nil {
+			failed = append(failed, SlaveFailure{Slave: server.Name, Error: err.Error()})
+			continue
+		}
+		total += count
+		results = append(results, rows...)
+	}
+	return results, total, failed, nil
+}
+
+// aggregateResources offset/limit 作用于合并后的结果，count 为成功查询的分节点的总数，
+// 部分分节点失败时返回 partial 并在 failed 中列出
+func aggregateResources(c *gin.Context, targets []models.JumpServer, limit, offset int) {
+	resourceType := c.Query("m_type")
+	if !aggregateResourceTypes[resourceType] {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request type",
+			"details": fmt.Sprintf("Request type %s does not support multiple slave nodes", resourceType),
+		})
+		return
+	}
+
+	if c.Query("cursor") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid filter",
+			"details": "cursor is not supported across multiple slave nodes, use offset instead",
+		})
+		return
+	}
+
+	fetch := func(server models.JumpServer, limit, offset int) ([]map[string]interface{}, int64, error) {
+		handler, err := newResourcesHandler(c, server)
+		if err != nil {
+			return nil, 0, err
+		}
+		handler.processedParams = map[string]bool{
+			"offset": true, "limit": true, "m_type": true, "search": true, "ranking": true,
+			"order": true, "cursor": true, "fields": true, "expand": true,
+		}
+		resources, count, err := handler.getAggregateResources(c, resourceType, limit, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		if resources, err = handler.projectFields(resources); err != nil {
+			return nil, 0, err
+		}
+		rows, err := annotateSlave(resources, server.Name)
+		return rows, count, err
+	}
+	results, total, failed, err := collectSlavePages(targets, limit, offset, fetch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid filter", "details": err.Error(),
+		})
+		return
+	}
+
+	if len(failed) == len(targets) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "Database error", "details": failed,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"results": results, "count": total, "partial": len(failed) > 0, "failed": failed,
+	})
+}
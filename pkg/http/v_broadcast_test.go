@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"middleman/pkg/middleware/models"
+)
+
+// newSlavePages 每个分节点返回 rows[name] 条记录，failing 中的分节点查询失败
+func newSlavePages(rows map[models.NameType]int, failing map[models.NameType]error) slavePageFetcher {
+	return func(server models.JumpServer, limit, offset int) ([]map[string]interface{}, int64, error) {
+		if err := failing[server.Name]; err != nil {
+			return nil, 0, err
+		}
+		count := rows[server.Name]
+		page := make([]map[string]interface{}, 0)
+		for i := offset; i < count && len(page) < limit; i++ {
+			page = append(page, map[string]interface{}{"id": fmt.Sprintf("%s-%d", server.Name, i)})
+		}
+		return page, int64(count), nil
+	}
+}
+
+func pageIds(rows []map[string]interface{}) []string {
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row["id"].(string))
+	}
+	return ids
+}
+
+func TestCollectSlavePages(t *testing.T) {
+	targets := []models.JumpServer{
+		{BaseJumpServer: models.BaseJumpServer{Name: "a"}},
+		{BaseJumpServer: models.BaseJumpServer{Name: "b"}},
+		{BaseJumpServer: models.BaseJumpServer{Name: "c"}},
+	}
+	rows := map[models.NameType]int{"a": 3, "b": 2, "c": 4}
+	down := errors.New("connection refused")
+
+	tests := []struct {
+		name       string
+		limit      int
+		offset     int
+		failing    map[models.NameType]error
+		wantIds    []string
+		wantTotal  int64
+		wantFailed []models.NameType
+	}{
+		{name: "first page", limit: 4, wantIds: []string{"a-0", "a-1", "a-2", "b-0"}, wantTotal: 9},
+		{name: "across slaves", limit: 4, offset: 2, wantIds: []string{"a-2", "b-0", "b-1", "c-0"}, wantTotal: 9},
+		{name: "last page", limit: 4, offset: 8, wantIds: []string{"c-3"}, wantTotal: 9},
+		{name: "failed slave left out of total and offset", limit: 4, offset: 2,
+			failing: map[models.NameType]error{"b": down},
+			wantIds: []string{"a-2", "c-0", "c-1", "c-2"}, wantTotal: 7, wantFailed: []models.NameType{"b"}},
+		{name: "failed first slave", limit: 2, offset: 1,
+			failing: map[models.NameType]error{"a": down},
+			wantIds: []string{"b-1", "c-0"}, wantTotal: 6, wantFailed: []models.NameType{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, total, failed, err := collectSlavePages(
+				targets, tt.limit, tt.offset, newSlavePages(rows, tt.failing),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pageIds(results); !reflect.DeepEqual(got, tt.wantIds) {
+				t.Errorf("results = %v, want %v", got, tt.wantIds)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", total, tt.wantTotal)
+			}
+			var failedNames []models.NameType
+			for _, failure := range failed {
+				failedNames = append(failedNames, failure.Slave)
+			}
+			if !reflect.DeepEqual(failedNames, tt.wantFailed) {
+				t.Errorf("failed = %v, want %v", failedNames, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestCollectSlavePagesFilterError(t *testing.T) {
+	targets := []models.JumpServer{{BaseJumpServer: models.BaseJumpServer{Name: "a"}}}
+	filterErr := &FilterError{Param: "name__foo", Msg: "unsupported operator"}
+	fetch := newSlavePages(nil, map[models.NameType]error{"a": filterErr})
+	if _, _, _, err := collectSlavePages(targets, 10, 0, fetch); !errors.Is(err, filterErr) {
+		t.Errorf("collectSlavePages() error = %v, want the filter error", err)
+	}
+}
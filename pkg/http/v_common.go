@@ -1,10 +1,12 @@
 package pkg
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"middleman/pkg/config"
 	"middleman/pkg/consts"
@@ -13,72 +15,131 @@ import (
 	"middleman/pkg/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
-	User          = "user"
-	Asset         = "asset"
-	Node          = "node"
-	ChildrenNode  = "children_node"
-	NodeWithAsset = "node_with_assets"
-	Account       = "account"
-	Platform      = "platform"
-	Permission    = "perm"
-	Host          = "host"
-	Device        = "device"
-	Database      = "database"
-	Cloud         = "cloud"
-	Web           = "web"
-	Gpt           = "gpt"
-	Custom        = "custom"
-	Organization  = "organization"
-	Role          = "role"
-	UserGroup     = "user_group"
-	UserUnblock   = "user_unblock"
-	UserResetMFA  = "user_reset_mfa"
+	User             = "user"
+	Asset            = "asset"
+	Node             = "node"
+	ChildrenNode     = "children_node"
+	NodeWithAsset    = "node_with_assets"
+	Account          = "account"
+	Platform         = "platform"
+	Permission       = "perm"
+	Host             = "host"
+	Device           = "device"
+	Database         = "database"
+	Cloud            = "cloud"
+	Web              = "web"
+	Gpt              = "gpt"
+	Custom           = "custom"
+	Organization     = "organization"
+	Role             = "role"
+	UserGroup        = "user_group"
+	UserGroupMembers = "user_group_members"
+	RoleBinding      = "role_binding"
+	UserUnblock      = "user_unblock"
+	UserResetMFA     = "user_reset_mfa"
+	UserActivate     = "user_activate"
+	UserDeactivate   = "user_deactivate"
 )
 
+var resourceTypes = map[string]bool{
+	User: true, Asset: true, Node: true, ChildrenNode: true, NodeWithAsset: true,
+	Account: true, Platform: true, Permission: true, Host: true, Device: true,
+	Database: true, Cloud: true, Web: true, Gpt: true, Custom: true,
+	Organization: true, Role: true, UserGroup: true, UserUnblock: true, UserResetMFA: true,
+	UserActivate: true, UserDeactivate: true, UserGroupMembers: true, RoleBinding: true,
+}
+
 type RegisterRequest struct {
-	Name           models.NameType `json:"name" binding:"required"`
-	Display        string          `json:"display" binding:"required"`
-	BootstrapToken string          `json:"bootstrap_token" binding:"required"`
-	Role           models.RoleType `json:"role" binding:"required"`
-	IgnoreSameName bool            `json:"ignore_same_name"`
-	Endpoint       string          `json:"endpoint" binding:"required"`
-	PrivateToken   string          `json:"private_token" binding:"required"`
+	Name            models.NameType `json:"name" binding:"required"`
+	Display         string          `json:"display" binding:"required"`
+	BootstrapToken  string          `json:"bootstrap_token"`
+	EnrollmentToken string          `json:"enrollment_token"`
+	Role            models.RoleType `json:"role" binding:"required"`
+	IgnoreSameName  bool            `json:"ignore_same_name"`
+	Endpoint        string          `json:"endpoint" binding:"required"`
+	PrivateToken    string          `json:"private_token" binding:"required"`
+	Labels          models.Labels   `json:"labels"`
 }
 
-func handleRegister(c *gin.Context) {
+// checkRegisterToken 注册需要一次性注册令牌，BootstrapToken 只能用于注册第一个主节点
+func checkRegisterToken(db *gorm.DB, req RegisterRequest) (*models.EnrollmentToken, error) {
+	if req.EnrollmentToken != "" {
+		var token models.EnrollmentToken
+		err := db.Where("token_hash = ?", models.HashEnrollmentToken(req.EnrollmentToken)).
+			Find(&token).Error
+		if err != nil {
+			return nil, err
+		}
+		if token.ID == 0 || token.GetStatus() != models.EnrollmentActive {
+			return nil, fmt.Errorf("EnrollmentToken is invalid, used, revoked or expired")
+		}
+		if token.NodeName != req.Name || token.Role != req.Role {
+			return nil, fmt.Errorf("EnrollmentToken does not match the node name or role")
+		}
+		return &token, nil
+	}
+
 	conf := config.GetConf()
+	if conf.BootstrapToken == "" || conf.BootstrapToken != req.BootstrapToken {
+		return nil, fmt.Errorf("BootstrapToken does not match")
+	}
+	if req.Role != models.RoleMaster {
+		return nil, fmt.Errorf("BootstrapToken can only register the master node")
+	}
+	var masterCount int64
+	if err := db.Model(&models.JumpServer{}).
+		Where("role = ?", models.RoleMaster).Count(&masterCount).Error; err != nil {
+		return nil, err
+	}
+	if masterCount > 0 {
+		return nil, fmt.Errorf("master node already exists, please use an EnrollmentToken")
+	}
+	return nil, nil
+}
+
+func handleRegister(c *gin.Context) {
 	var registerRequest RegisterRequest
 	if err := c.ShouldBindJSON(&registerRequest); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if conf.BootstrapToken != registerRequest.BootstrapToken {
-		c.JSON(http.StatusForbidden, gin.H{"error": "BootstrapToken does not match"})
+	db := database.GetDBManager().GetDefaultDB()
+	enrollment, err := checkRegisterToken(db, registerRequest)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
 	}
-	db := database.GetDBManager().GetDefaultDB()
 	server := models.JumpServer{
 		BaseJumpServer: models.BaseJumpServer{
 			Name:     registerRequest.Name,
 			Display:  registerRequest.Display,
 			Role:     registerRequest.Role,
 			Endpoint: registerRequest.Endpoint,
+			Labels:   registerRequest.Labels,
 		},
 		AccessKey:    utils.GenerateRandomString(36),
 		SecretKey:    utils.GenerateRandomString(36),
 		PrivateToken: registerRequest.PrivateToken,
 	}
-	if err := server.Validate(); err != nil {
+	if err = server.Validate(); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	var count int64
-	db.Model(&models.JumpServer{}).Where("name = ?", registerRequest.Name).Count(&count)
+	// 只读取角色，不加载完整模型
+	var existingRoles []models.RoleType
+	if err = db.Model(&models.JumpServer{}).Where("name = ?", registerRequest.Name).
+		Pluck("role", &existingRoles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	count := int64(len(existingRoles))
 	if count > 0 && !registerRequest.IgnoreSameName {
 		msg := fmt.Sprintf(
 			"Name: %s 重复，请修改配置文件 MIDDLEMAN_SERVICE_NAME，并重启重新注册",
@@ -87,29 +148,76 @@ func handleRegister(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 		return
 	}
-	if count == 0 {
-		if err := db.Create(&server).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": fmt.Sprintf("创建节点失败: %v", err),
+	if count > 0 {
+		// 注册令牌只能注册新节点，已有节点需要通过密钥轮换接口更换密钥
+		if enrollment != nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": fmt.Sprintf("Node %s is already registered, please rotate its keys instead", server.Name),
+			})
+			return
+		}
+		if existingRoles[0] != registerRequest.Role {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": fmt.Sprintf("Node %s is already registered as %s", server.Name, existingRoles[0]),
 			})
 			return
 		}
+	}
 
-		if server.Role == models.RoleSlave {
-			_, err := database.GetDBManager().GetDB(string(server.Name))
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if enrollment != nil {
+			now := time.Now()
+			result := tx.Model(&models.EnrollmentToken{}).
+				Where("id = ? AND used_at IS NULL AND revoked_at IS NULL AND expired_at > ?",
+					enrollment.ID, now).
+				Updates(map[string]interface{}{
+					"used_at":      now,
+					"used_by":      string(server.Name),
+					"used_from_ip": c.ClientIP(),
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected != 1 {
+				return fmt.Errorf("EnrollmentToken has already been used")
 			}
 		}
-	} else {
-		err := db.Model(&models.JumpServer{}).
-			Where("name = ?", registerRequest.Name).
-			Updates(map[string]interface{}{
-				"access_key": server.AccessKey,
-				"secret_key": server.SecretKey,
-				"display":    server.Display,
-			}).Error
+
+		if count == 0 {
+			// BeforeSave 会加密字段，响应中需要返回明文密钥
+			record := server
+			if txErr := tx.Create(&record).Error; txErr != nil {
+				return fmt.Errorf("创建节点失败: %v", txErr)
+			}
+			return nil
+		}
+
+		secretKey, txErr := server.Encrypt(server.SecretKey)
+		if txErr != nil {
+			return txErr
+		}
+		updates := map[string]interface{}{
+			"access_key":          server.AccessKey,
+			"secret_key":          secretKey,
+			"display":             server.Display,
+			"prev_access_key":     "",
+			"prev_secret_key":     "",
+			"prev_key_expired_at": nil,
+		}
+		if registerRequest.Labels != nil {
+			updates["labels"] = registerRequest.Labels
+		}
+		return tx.Model(&models.JumpServer{}).
+			Where("name = ? AND role = ?", registerRequest.Name, registerRequest.Role).
+			Updates(updates).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if count == 0 && server.Role == models.RoleSlave {
+		_, err = database.GetDBManager().GetDB(string(server.Name))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -120,31 +228,42 @@ func handleRegister(c *gin.Context) {
 
 func getSlaveNodes(c *gin.Context) {
 	db := database.GetDBManager().GetDefaultDB()
+	q := db.Where("role = ?", models.RoleSlave)
+	if health := c.Query("health"); health != "" {
+		switch health {
+		case models.HealthHealthy, models.HealthUnhealthy, models.HealthUnknown:
+			q = q.Where("status = ?", health)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid health", "details": "health must be healthy, unhealthy or unknown",
+			})
+			return
+		}
+	}
+
+	var selector models.LabelSelector
+	if text := c.Query("label_selector"); text != "" {
+		var err error
+		if selector, err = models.ParseLabelSelector(text); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label_selector", "details": err.Error()})
+			return
+		}
+	}
+
 	var services []models.JumpServer
-	db.Where("role = ?", models.RoleSlave).Find(&services)
+	q.Order("name").Find(&services)
 
-	var baseServices []models.BaseJumpServer
+	baseServices := make([]models.BaseJumpServer, 0, len(services))
 	for _, s := range services {
+		if selector != nil && !selector.Matches(s.Labels) {
+			continue
+		}
 		baseServices = append(baseServices, s.BaseJumpServer)
 	}
 	c.JSON(http.StatusOK, gin.H{"data": baseServices, "total": len(baseServices)})
 }
 
 func getResources(c *gin.Context) {
-	dbInfo := c.MustGet(consts.DBInfoContextKey).(models.JumpServer)
-	fmt.Println("DB Name:", dbInfo.Name)
-
-	handle, err := newResourcesHandler(dbInfo)
-	if err != nil {
-		c.JSON(http.StatusPreconditionFailed, gin.H{
-			"error": "Database init failed", "details": "Database init failed",
-		})
-		return
-	}
-	handle.processedParams = map[string]bool{
-		"offset": true, "limit": true, "m_type": true, "search": true,
-	}
-
 	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
 	if err != nil || offset < 0 {
 		offset = 0
@@ -159,6 +278,26 @@ func getResources(c *gin.Context) {
 		return
 	}
 
+	if targets, ok := broadcastTargets(c); ok {
+		aggregateResources(c, targets, limit, offset)
+		return
+	}
+
+	dbInfo := c.MustGet(consts.DBInfoContextKey).(models.JumpServer)
+	fmt.Println("DB Name:", dbInfo.Name)
+
+	handle, err := newResourcesHandler(c, dbInfo)
+	if err != nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "Database init failed", "details": "Database init failed",
+		})
+		return
+	}
+	handle.processedParams = map[string]bool{
+		"offset": true, "limit": true, "m_type": true, "search": true, "ranking": true,
+		"order": true, "cursor": true, "fields": true, "expand": true,
+	}
+
 	var resources interface{}
 	var count int64
 	resourceType := c.Query("m_type")
@@ -177,6 +316,10 @@ func getResources(c *gin.Context) {
 		resources, count, err = handle.getPerms(c, limit, offset)
 	case Node:
 		resources, count, err = handle.getNodes(c, limit, offset)
+	case Organization:
+		resources, count, err = handle.getOrgs(c, limit, offset)
+	case RoleBinding:
+		resources, count, err = handle.getRoleBindings(c, limit, offset)
 	case ChildrenNode:
 		resources, count, err = handle.getChildrenNodes(c)
 	default:
@@ -187,6 +330,13 @@ func getResources(c *gin.Context) {
 		return
 	}
 
+	var filterErr *FilterError
+	if errors.As(err, &filterErr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid filter", "details": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusPreconditionFailed, gin.H{
 			"error": "Database error", "details": err.Error(),
@@ -199,78 +349,292 @@ func getResources(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"results": resources, "count": count})
+	if resources, err = handle.projectFields(resources); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := gin.H{"results": resources}
+	if !handle.skipCount {
+		resp["count"] = count
+	}
+	if handle.nextCursor != "" {
+		resp["next"] = handle.nextCursor
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// checkResourceID platform 的 id 为自增整数，其它资源的 id 均为 UUID
+func checkResourceID(resourceType, id string) error {
+	if resourceType == Platform {
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			return fmt.Errorf("Resource[%s] id must be an integer: %s", resourceType, id)
+		}
+		return nil
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("Resource[%s] id must be a UUID: %s", resourceType, id)
+	}
+	return nil
+}
+
+func getResource(c *gin.Context) {
+	id := c.Param("id")
+	resourceType := c.Query("m_type")
+	if err := checkResourceID(resourceType, id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid resource id", "details": err.Error()})
+		return
+	}
+	dbInfo, exists := c.Get(consts.DBInfoContextKey)
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid branch node name",
+			"details": "Resource detail must target exactly one slave node",
+		})
+		return
+	}
+	handler, err := newResourcesHandler(c, dbInfo.(models.JumpServer))
+	if err != nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "Database init failed", "details": "Database init failed",
+		})
+		return
+	}
+
+	var resource interface{}
+	switch resourceType {
+	case User:
+		resource, err = handler.getUser(id)
+	case Role:
+		resource, err = handler.getRole(id)
+	case RoleBinding:
+		resource, err = handler.getRoleBinding(id)
+	case UserGroup:
+		resource, err = handler.getUserGroup(id)
+	case Platform:
+		resource, err = handler.getPlatform(id)
+	case Asset:
+		resource, err = handler.getAsset(id, "")
+	case Host, Web, Device, Database, Custom, Gpt, Cloud:
+		resource, err = handler.getAsset(id, resourceType)
+	case Account:
+		resource, err = handler.getAccount(id)
+	case Permission:
+		resource, err = handler.getPerm(id)
+	case Node:
+		resource, err = handler.getNode(id)
+	case Organization:
+		resource, err = handler.getOrg(id)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request type",
+			"details": fmt.Sprintf("Invalid request type: %s", resourceType),
+		})
+		return
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Resource not found",
+			"details": fmt.Sprintf("Resource[%s] %s not found", resourceType, id),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "Database error", "details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": resource})
+}
+
+// statusError 需要以指定状态码返回的错误，例如冲突或参数错误
+type statusError struct {
+	status int
+	msg    string
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
+
+func newStatusError(status int, format string, args ...interface{}) error {
+	return &statusError{status: status, msg: fmt.Sprintf(format, args...)}
+}
+
+// errResourceNotFound 删除时目标资源不存在
+var errResourceNotFound error = &statusError{status: http.StatusNotFound, msg: "Resource not found"}
+
+// statusOf 返回 statusError 的状态码，其余错误按数据库错误处理
+func statusOf(err error) (int, bool) {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.status, true
+	}
+	return 0, false
 }
 
 type ResourcesHandler struct {
 	jmsClient *utils.JumpServer
 	db        *gorm.DB
 	dbName    string
+	orgID     string
 
 	processedParams map[string]bool
+
+	// 分页状态，由 handlePage 设置，setNextCursor 生成下一页游标，
+	// countPage 在游标分页的后续页设置 skipCount
+	pageOrders []orderField
+	pageLimit  int
+	nextCursor string
+	skipCount  bool
+
+	// fields 及 expand 参数，由 handleFields、handleExpand 设置
+	fields     []string
+	expandKeys []string
 }
 
+// handleSearch 大小写不敏感的模糊搜索，多个关键词之间为且，关键词匹配任一字段即可。
+// ranking=true 时按 pg_trgm 相似度排序，分节点库没有 pg_trgm 时忽略 ranking
 func (h *ResourcesHandler) handleSearch(c *gin.Context, q *gorm.DB, searchFields []string) *gorm.DB {
-	search := c.Query("search")
-	if search != "" && len(searchFields) > 0 {
+	terms := strings.Fields(c.Query("search"))
+	if len(terms) == 0 || len(searchFields) == 0 {
+		return q
+	}
+
+	for _, term := range terms {
 		var conditions []string
 		var args []interface{}
+		pattern := "%" + escapeLike(term) + "%"
 		for _, f := range searchFields {
-			args = append(args, search)
-			conditions = append(conditions, fmt.Sprintf("%s = ?", f))
+			args = append(args, pattern)
+			conditions = append(conditions, fmt.Sprintf("%s ILIKE ?", f))
 		}
-
 		q = q.Where("("+strings.Join(conditions, " OR ")+")", args...)
 	}
+
+	ranking, _ := strconv.ParseBool(c.Query("ranking"))
+	if ranking && database.GetDBManager().HasTrgm(h.dbName) {
+		var scores []string
+		var args []interface{}
+		search := strings.Join(terms, " ")
+		for _, f := range searchFields {
+			args = append(args, search)
+			scores = append(scores, fmt.Sprintf("similarity(%s, ?)", f))
+		}
+		q = q.Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "GREATEST(" + strings.Join(scores, ", ") + ") DESC",
+			Vars:               args,
+			WithoutParentheses: true,
+		}})
+	}
 	return q
 }
 
-func newResourcesHandler(dbInfo models.JumpServer) (*ResourcesHandler, error) {
+// newResourcesHandler 组织由 OrgMiddleware 设置，未经过中间件时使用默认组织
+func newResourcesHandler(c *gin.Context, dbInfo models.JumpServer) (*ResourcesHandler, error) {
 	db, err := database.GetDBManager().GetDB(string(dbInfo.Name))
 	if err != nil {
 		return nil, err
 	}
+	privateToken, err := dbInfo.GetPrivateToken()
+	if err != nil {
+		return nil, fmt.Errorf("读取 Private token 失败: %w", err)
+	}
+	orgID := requestOrgID(c)
+	jmsClient := utils.NewJumpServer(dbInfo.Endpoint, privateToken)
 	return &ResourcesHandler{
-		jmsClient: utils.NewJumpServer(dbInfo.Endpoint, dbInfo.PrivateToken),
-		db:        db, dbName: string(dbInfo.Name),
+		jmsClient: jmsClient.WithOrg(orgID),
+		db:        db, dbName: string(dbInfo.Name), orgID: orgID,
 	}, nil
 }
 
-func saveResources(c *gin.Context) {
-	var err error
-	dbInfo := c.MustGet(consts.DBInfoContextKey).(models.JumpServer)
-	cache := utils.GetCache()
+// orgScope 将查询限制在当前组织内
+func (h *ResourcesHandler) orgScope(q *gorm.DB, table string) *gorm.DB {
+	return q.Where(fmt.Sprintf("%s.org_id = ?", table), h.orgID)
+}
 
-	handler, err := newResourcesHandler(dbInfo)
-	if err != nil {
-		c.JSON(http.StatusPreconditionFailed, gin.H{
-			"error": "Database init failed", "details": "Database init failed",
-		})
-		return
+// checkResourceOrg 已存在的资源只能在所属组织中更新，不能通过保存移动到其他组织
+func (h *ResourcesHandler) checkResourceOrg(model interface{}, resourceType, id string) error {
+	var orgIDs []string
+	if err := h.db.Model(model).Select("org_id").Where("id = ?", id).Limit(1).
+		Find(&orgIDs).Error; err != nil {
+		return err
+	}
+	if len(orgIDs) > 0 && orgIDs[0] != h.orgID {
+		return newStatusError(http.StatusConflict,
+			"%s %s already exists in another organization", resourceType, id)
 	}
+	return nil
+}
 
-	resourceType := c.Query("m_type")
+var saveResourceTypes = map[string]bool{
+	User:             true,
+	Role:             true,
+	UserGroup:        true,
+	Platform:         true,
+	Account:          true,
+	Host:             true,
+	Web:              true,
+	Device:           true,
+	Database:         true,
+	Cloud:            true,
+	Gpt:              true,
+	Custom:           true,
+	Permission:       true,
+	ChildrenNode:     true,
+	Node:             true,
+	NodeWithAsset:    true,
+	Organization:     true,
+	UserGroupMembers: true,
+	RoleBinding:      true,
+}
+
+func (h *ResourcesHandler) saveResource(c *gin.Context, resourceType string) error {
+	var err error
 	var ids []string
 	switch resourceType {
 	case User:
-		ids, err = handler.saveUser(c)
+		ids, err = h.saveUser(c)
 	case Role:
-		err = handler.saveRole(c)
+		err = h.saveRole(c)
 	case UserGroup:
-		err = handler.saveUserGroup(c)
+		err = h.saveUserGroup(c)
+	case UserGroupMembers:
+		err = h.userGroupRelation(c)
+	case RoleBinding:
+		ids, err = h.saveRoleBinding(c)
 	case Platform:
-		err = handler.savePlatform(c)
-	case Host:
-		ids, err = handler.saveHost(c)
+		err = h.savePlatform(c)
+	case Account:
+		ids, err = h.saveAccount(c)
+	case Host, Web, Device, Database, Cloud, Gpt, Custom:
+		ids, err = h.saveAsset(c, resourceType)
 	case Permission:
-		ids, err = handler.savePerm(c)
+		ids, err = h.savePerm(c)
 	case ChildrenNode:
-		ids, err = handler.saveChildrenNode(c)
+		ids, err = h.saveChildrenNode(c)
 	case Node:
-		err = handler.saveNode(c)
+		err = h.saveNode(c)
 	case NodeWithAsset:
-		err = handler.assetNodeRelation(c)
-	default:
+		err = h.assetNodeRelation(c)
+	case Organization:
+		ids, err = h.saveOrg(c)
+	}
+	if err != nil {
+		return err
+	}
+
+	cache := utils.GetCache()
+	for _, id := range ids {
+		_ = cache.Set(fmt.Sprintf("%s-%s", resourceType, id), "", 0)
+	}
+	return nil
+}
+
+func saveResources(c *gin.Context) {
+	resourceType := c.Query("m_type")
+	if !saveResourceTypes[resourceType] {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request type",
 			"details": fmt.Sprintf("Invalid request type: %s", resourceType),
@@ -278,7 +642,27 @@ func saveResources(c *gin.Context) {
 		return
 	}
 
+	if targets, ok := broadcastTargets(c); ok {
+		broadcast(c, targets, http.StatusCreated, func(handler *ResourcesHandler) error {
+			return handler.saveResource(c, resourceType)
+		})
+		return
+	}
+
+	dbInfo := c.MustGet(consts.DBInfoContextKey).(models.JumpServer)
+	handler, err := newResourcesHandler(c, dbInfo)
 	if err != nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "Database init failed", "details": "Database init failed",
+		})
+		return
+	}
+
+	if err = handler.saveResource(c, resourceType); err != nil {
+		if status, ok := statusOf(err); ok {
+			c.JSON(status, gin.H{"error": err.Error(), "details": http.StatusText(status)})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   fmt.Sprintf("Failed to save resource: %v", err.Error()),
 			"details": "Database operation failed",
@@ -286,23 +670,57 @@ func saveResources(c *gin.Context) {
 		return
 	}
 
-	for _, id := range ids {
-		_ = cache.Set(fmt.Sprintf("%s-%s", resourceType, id), "", 0)
-	}
-
 	c.JSON(http.StatusCreated, gin.H{
 		"message": fmt.Sprintf("Resource[%s] created successfully", resourceType),
 	})
 }
 
+func (h *ResourcesHandler) updateResource(c *gin.Context, resourceType, id string) error {
+	switch resourceType {
+	case Node:
+		return h.updateNode(c, id)
+	case User:
+		return h.updateUser(c, id)
+	case UserGroup:
+		return h.updateUserGroup(c, id)
+	case UserUnblock:
+		return h.unblockUser(id)
+	case UserResetMFA:
+		return h.resetUserMFA(id)
+	case UserActivate:
+		return h.setUserActive(id, true)
+	case UserDeactivate:
+		return h.setUserActive(id, false)
+	case Permission:
+		return h.updatePerm(c, id)
+	case Account:
+		return h.updateAccount(c, id)
+	case Host, Web, Device, Database, Cloud, Gpt, Custom:
+		return h.updateAsset(c, resourceType, id)
+	}
+	return nil
+}
+
 func updateResources(c *gin.Context) {
 	var err error
 
 	validResourceTypes := map[string]bool{
-		Node:         true,
-		UserUnblock:  true,
-		UserResetMFA: true,
-		Permission:   true,
+		Node:           true,
+		User:           true,
+		UserGroup:      true,
+		UserUnblock:    true,
+		UserResetMFA:   true,
+		UserActivate:   true,
+		UserDeactivate: true,
+		Permission:     true,
+		Account:        true,
+		Host:           true,
+		Web:            true,
+		Device:         true,
+		Database:       true,
+		Cloud:          true,
+		Gpt:            true,
+		Custom:         true,
 	}
 	resourceType := c.Query("m_type")
 	if !validResourceTypes[resourceType] {
@@ -313,8 +731,16 @@ func updateResources(c *gin.Context) {
 		return
 	}
 
+	id := c.Param("id")
+	if targets, ok := broadcastTargets(c); ok {
+		broadcast(c, targets, http.StatusAccepted, func(handler *ResourcesHandler) error {
+			return handler.updateResource(c, resourceType, id)
+		})
+		return
+	}
+
 	dbInfo := c.MustGet(consts.DBInfoContextKey).(models.JumpServer)
-	handler, err := newResourcesHandler(dbInfo)
+	handler, err := newResourcesHandler(c, dbInfo)
 	if err != nil {
 		c.JSON(http.StatusPreconditionFailed, gin.H{
 			"error": "Database init failed", "details": "Database init failed",
@@ -322,18 +748,18 @@ func updateResources(c *gin.Context) {
 		return
 	}
 
-	id := c.Param("id")
-	switch resourceType {
-	case Node:
-		err = handler.updateNode(c, id)
-	case UserUnblock:
-		err = handler.unblockUser(id)
-	case UserResetMFA:
-		err = handler.resetUserMFA(id)
-	case Permission:
-		err = handler.updatePerm(c, id)
-	}
-	if err != nil {
+	if err = handler.updateResource(c, resourceType, id); err != nil {
+		if status, ok := statusOf(err); ok {
+			c.JSON(status, gin.H{"error": err.Error(), "details": http.StatusText(status)})
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Resource not found",
+				"details": fmt.Sprintf("Resource[%s] %s not found", resourceType, id),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   fmt.Sprintf("Failed to save resource: %v", err.Error()),
 			"details": "Database operation failed",
@@ -353,8 +779,12 @@ func deleteResource(c *gin.Context) {
 	var handlers []*ResourcesHandler
 
 	validResourceTypes := map[string]bool{
-		Permission: true,
-		Asset:      true,
+		Permission:  true,
+		Asset:       true,
+		Account:     true,
+		User:        true,
+		UserGroup:   true,
+		RoleBinding: true,
 	}
 
 	resourceType := c.Query("m_type")
@@ -369,13 +799,19 @@ func deleteResource(c *gin.Context) {
 	cache := utils.GetCache()
 	id := c.Param("id")
 	cacheKey := fmt.Sprintf("%s-%s", resourceType, id)
-	err = cache.Get(cacheKey, &dbName)
+	authServer := c.MustGet(consts.AuthDBInfoContextKey).(models.JumpServer)
+	if authServer.Role == models.RoleSlave {
+		// 分节点只能删除自身数据库中的资源
+		dbName = string(authServer.Name)
+	} else {
+		err = cache.Get(cacheKey, &dbName)
+	}
 	defaultDB := database.GetDBManager().GetDefaultDB()
 	if err != nil || dbName == "" {
 		var servers []models.JumpServer
 		defaultDB.Model(models.JumpServer{}).Where("role = ?", models.RoleSlave).Find(&servers)
 		for _, server := range servers {
-			handler, err = newResourcesHandler(server)
+			handler, err = newResourcesHandler(c, server)
 			if err != nil {
 				c.JSON(http.StatusPreconditionFailed, gin.H{
 					"error": "Database init failed", "details": "Database init failed",
@@ -387,7 +823,7 @@ func deleteResource(c *gin.Context) {
 	} else {
 		var server models.JumpServer
 		defaultDB.Model(models.JumpServer{}).Where("name = ?", dbName).Find(&server)
-		handler, err = newResourcesHandler(server)
+		handler, err = newResourcesHandler(c, server)
 		if err != nil {
 			c.JSON(http.StatusPreconditionFailed, gin.H{
 				"error": "Database init failed", "details": "Database init failed",
@@ -397,20 +833,62 @@ func deleteResource(c *gin.Context) {
 		handlers = append(handlers, handler)
 	}
 
+	respondError := func(err error) {
+		if status, ok := statusOf(err); ok {
+			c.JSON(status, gin.H{"error": err.Error(), "details": http.StatusText(status)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   fmt.Sprintf("Failed to delete resource: %v", err.Error()),
+			"details": "Database operation failed",
+		})
+	}
+
+	// 未缓存位置时用户可能存在于多个分节点，先在全部分节点上检查，
+	// 避免部分分节点已经删除后才发现是最后一个系统管理员
+	if resourceType == User {
+		var targets []*ResourcesHandler
+		for _, handler = range handlers {
+			found, err := handler.canDeleteUser(id)
+			if err != nil {
+				respondError(err)
+				return
+			}
+			if found {
+				targets = append(targets, handler)
+			}
+		}
+		handlers = targets
+	}
+
+	deleted := 0
 	for _, handler = range handlers {
 		switch resourceType {
 		case Permission:
 			err = handler.deletePerm(id, cacheKey)
 		case Asset:
 			err = handler.deleteAsset(id, cacheKey)
+		case Account:
+			err = handler.deleteAccount(id, cacheKey)
+		case User:
+			err = handler.deleteUser(id, cacheKey)
+		case UserGroup:
+			err = handler.deleteUserGroup(id, cacheKey)
+		case RoleBinding:
+			err = handler.deleteRoleBinding(id, cacheKey)
+		}
+		if errors.Is(err, errResourceNotFound) {
+			continue
 		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   fmt.Sprintf("Failed to delete resource: %v", err.Error()),
-				"details": "Database operation failed",
-			})
+			respondError(err)
 			return
 		}
+		deleted++
+	}
+	if deleted == 0 {
+		respondError(errResourceNotFound)
+		return
 	}
 	c.JSON(http.StatusAccepted, gin.H{
 		"message": fmt.Sprintf("Delete resource[%s] task create success", resourceType),
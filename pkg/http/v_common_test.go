@@ -0,0 +1,124 @@
+package pkg
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"middleman/pkg/config"
+	"middleman/pkg/middleware/models"
+)
+
+// newRegisterDB 查询注册令牌时返回 token，统计主节点时返回 masterCount
+func newRegisterDB(t *testing.T, token *models.EnrollmentToken, masterCount int64) *gorm.DB {
+	t.Helper()
+	db := newDryRunDB(t)
+	err := db.Callback().Query().After("gorm:after_query").Register("test:register", func(tx *gorm.DB) {
+		switch dest := tx.Statement.Dest.(type) {
+		case *models.EnrollmentToken:
+			if token != nil {
+				*dest = *token
+				tx.RowsAffected = 1
+			}
+		case *int64:
+			*dest = masterCount
+			tx.RowsAffected = masterCount
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestCheckRegisterToken(t *testing.T) {
+	conf := config.GetConf()
+	conf.BootstrapToken = "bootstrap"
+	prev := config.GlobalConfig
+	config.GlobalConfig = &conf
+	defer func() { config.GlobalConfig = prev }()
+
+	now := time.Now()
+	active := &models.EnrollmentToken{
+		ID: 1, NodeName: "slave-1", Role: models.RoleSlave, ExpiredAt: now.Add(time.Hour),
+	}
+	used, expired, revoked := *active, *active, *active
+	used.UsedAt = &now
+	expired.ExpiredAt = now.Add(-time.Hour)
+	revoked.RevokedAt = &now
+
+	slave := RegisterRequest{Name: "slave-1", Role: models.RoleSlave, EnrollmentToken: "token"}
+	master := RegisterRequest{Name: "master", Role: models.RoleMaster, BootstrapToken: "bootstrap"}
+	tests := []struct {
+		name        string
+		req         RegisterRequest
+		token       *models.EnrollmentToken
+		masterCount int64
+		wantToken   bool
+		wantErr     bool
+	}{
+		{name: "enrollment token", req: slave, token: active, wantToken: true},
+		{name: "unknown enrollment token", req: slave, wantErr: true},
+		{name: "used enrollment token", req: slave, token: &used, wantErr: true},
+		{name: "expired enrollment token", req: slave, token: &expired, wantErr: true},
+		{name: "revoked enrollment token", req: slave, token: &revoked, wantErr: true},
+		{name: "enrollment token for another node", token: active, wantErr: true,
+			req: RegisterRequest{Name: "slave-2", Role: models.RoleSlave, EnrollmentToken: "token"}},
+		{name: "enrollment token for another role", token: active, wantErr: true,
+			req: RegisterRequest{Name: "slave-1", Role: models.RoleMaster, EnrollmentToken: "token"}},
+		{name: "bootstrap first master", req: master},
+		{name: "bootstrap second master", req: master, masterCount: 1, wantErr: true},
+		{name: "bootstrap slave", wantErr: true,
+			req: RegisterRequest{Name: "slave-1", Role: models.RoleSlave, BootstrapToken: "bootstrap"}},
+		{name: "wrong bootstrap token", wantErr: true,
+			req: RegisterRequest{Name: "master", Role: models.RoleMaster, BootstrapToken: "other"}},
+		{name: "no token", wantErr: true, req: RegisterRequest{Name: "master", Role: models.RoleMaster}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := checkRegisterToken(newRegisterDB(t, tt.token, tt.masterCount), tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkRegisterToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (token != nil) != tt.wantToken {
+				t.Errorf("checkRegisterToken() token = %v, want token %v", token, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestCheckResourceID(t *testing.T) {
+	tests := []struct {
+		name         string
+		resourceType string
+		id           string
+		wantErr      bool
+	}{
+		{name: "uuid", resourceType: User, id: "1c5b7e5a-3f52-4c1e-9f0e-2b6c8d4a9e10"},
+		{name: "platform id", resourceType: Platform, id: "12"},
+		{name: "malformed uuid", resourceType: Host, id: "12", wantErr: true},
+		{name: "sql in id", resourceType: Account, id: "1' OR '1'='1", wantErr: true},
+		{name: "malformed platform id", resourceType: Platform, id: "linux", wantErr: true},
+		{name: "negative platform id", resourceType: Platform, id: "-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkResourceID(tt.resourceType, tt.id); (err != nil) != tt.wantErr {
+				t.Errorf("checkResourceID(%s, %q) error = %v, wantErr %v", tt.resourceType, tt.id, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// 非法 id 在访问数据库之前返回 400
+func TestGetResourceInvalidID(t *testing.T) {
+	c, w := newAccountContext(http.MethodGet, "/middleman/resources/abc/?m_type=user", "")
+	c.Params = gin.Params{{Key: "id", Value: "abc"}}
+	getResource(c)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("getResource() status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
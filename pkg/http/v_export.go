@@ -0,0 +1,335 @@
+package pkg
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"middleman/pkg/consts"
+	"middleman/pkg/database/models"
+	mm "middleman/pkg/middleware/models"
+	"middleman/pkg/utils"
+)
+
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+	ExportXLSX   = "xlsx"
+
+	exportBatchSize = 500
+)
+
+var exportContentTypes = map[string]string{
+	ExportCSV:    "text/csv; charset=utf-8",
+	ExportNDJSON: "application/x-ndjson",
+	ExportXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// 各资源导出的默认列，可以通过 fields 参数指定
+var exportColumns = map[string][]string{
+	User: {
+		"id", "username", "name", "email", "source", "is_active", "is_valid",
+		"groups", "system_roles", "org_roles", "date_joined", "date_expired", "last_login", "comment",
+	},
+	Platform: {"id", "name", "type", "category", "internal", "comment", "date_created"},
+	Asset: {
+		"id", "name", "address", "category", "type", "platform", "protocols",
+		"nodes_display", "is_active", "comment", "date_created",
+	},
+	Account: {"id", "name", "username", "secret_type", "privileged", "is_active", "asset", "date_created"},
+	Permission: {
+		"id", "name", "is_active", "is_valid", "users", "user_groups", "assets", "nodes",
+		"accounts", "protocols", "actions", "date_start", "date_expired", "comment",
+	},
+	Node: {"id", "key", "value", "full_value", "parent_key", "assets_amount"},
+}
+
+// exportSource 分批查询某类资源，convert 把当前批次转换为与列表接口一致的结构
+type exportSource struct {
+	query   *gorm.DB
+	dest    interface{}
+	convert func() interface{}
+}
+
+func (h *ResourcesHandler) exportSource(c *gin.Context, resourceType string) (*exportSource, error) {
+	var err error
+	var q *gorm.DB
+	switch resourceType {
+	case User:
+		var users []models.User
+		if q, err = h.userQuery(c); err != nil {
+			return nil, err
+		}
+		return &exportSource{query: q, dest: &users, convert: func() interface{} {
+			rows := make([]respUser, 0, len(users))
+			for _, user := range users {
+				rows = append(rows, newRespUser(user))
+			}
+			return rows
+		}}, nil
+	case Platform:
+		var platforms []models.Platform
+		if q, err = h.platformQuery(c); err != nil {
+			return nil, err
+		}
+		return &exportSource{query: q, dest: &platforms, convert: func() interface{} {
+			return platforms
+		}}, nil
+	case Asset, Host, Web, Device, Database, Custom, Gpt, Cloud:
+		category := resourceType
+		if category == Asset {
+			category = ""
+		}
+		var assets []models.Asset
+		if q, err = h.assetQuery(c, category); err != nil {
+			return nil, err
+		}
+		return &exportSource{query: q, dest: &assets, convert: func() interface{} {
+			rows := make([]respAsset, 0, len(assets))
+			for _, asset := range assets {
+				asset.Accounts = nil
+				rows = append(rows, newRespAsset(asset))
+			}
+			return rows
+		}}, nil
+	case Account:
+		var accounts []models.Account
+		if q, err = h.accountQuery(c); err != nil {
+			return nil, err
+		}
+		return &exportSource{query: q, dest: &accounts, convert: func() interface{} {
+			return accounts
+		}}, nil
+	case Permission:
+		var perms []models.AssetPermission
+		if q, err = h.permQuery(c); err != nil {
+			return nil, err
+		}
+		return &exportSource{query: q, dest: &perms, convert: func() interface{} {
+			for i := range perms {
+				perms[i].Valid = perms[i].IsValid()
+			}
+			return perms
+		}}, nil
+	case Node:
+		var nodes []models.Node
+		if q, err = h.nodeQuery(c); err != nil {
+			return nil, err
+		}
+		return &exportSource{query: q, dest: &nodes, convert: func() interface{} {
+			return nodes
+		}}, nil
+	}
+	return nil, &FilterError{Param: "m_type", Msg: fmt.Sprintf("export of %s is not supported", resourceType)}
+}
+
+// flattenValue 把关联展开为可读的文本，如节点取完整路径、用户组和角色取名称
+func flattenValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, flattenValue(item))
+		}
+		return strings.Join(items, "; ")
+	case map[string]interface{}:
+		if name, ok := v["name"]; ok {
+			if port, ok := v["port"]; ok {
+				return fmt.Sprintf("%s/%s", flattenValue(name), flattenValue(port))
+			}
+		}
+		for _, key := range []string{"label", "full_value", "name", "username", "value", "id"} {
+			if item, ok := v[key]; ok && item != nil {
+				return flattenValue(item)
+			}
+		}
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+type exportWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(columns []string, row map[string]interface{}) error
+	Flush() error
+	Close() error
+}
+
+type csvExportWriter struct{ w *csv.Writer }
+
+func (e *csvExportWriter) WriteHeader(columns []string) error { return e.w.Write(columns) }
+func (e *csvExportWriter) Flush() error                       { e.w.Flush(); return e.w.Error() }
+func (e *csvExportWriter) Close() error                       { return e.Flush() }
+
+func (e *csvExportWriter) WriteRow(columns []string, row map[string]interface{}) error {
+	record := make([]string, 0, len(columns))
+	for _, column := range columns {
+		record = append(record, flattenValue(row[column]))
+	}
+	return e.w.Write(record)
+}
+
+type ndjsonExportWriter struct {
+	w   io.Writer
+	enc *json.Encoder
+}
+
+func (e *ndjsonExportWriter) WriteHeader(columns []string) error { return nil }
+func (e *ndjsonExportWriter) Close() error                       { return e.Flush() }
+
+func (e *ndjsonExportWriter) Flush() error {
+	if f, ok := e.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func (e *ndjsonExportWriter) WriteRow(columns []string, row map[string]interface{}) error {
+	record := make(map[string]string, len(columns))
+	for _, column := range columns {
+		record[column] = flattenValue(row[column])
+	}
+	return e.enc.Encode(record)
+}
+
+type xlsxExportWriter struct{ w *utils.XLSXWriter }
+
+func (e *xlsxExportWriter) WriteHeader(columns []string) error { return e.w.WriteRow(columns) }
+func (e *xlsxExportWriter) Flush() error                       { return e.w.Flush() }
+func (e *xlsxExportWriter) Close() error                       { return e.w.Close() }
+
+func (e *xlsxExportWriter) WriteRow(columns []string, row map[string]interface{}) error {
+	record := make([]string, 0, len(columns))
+	for _, column := range columns {
+		record = append(record, flattenValue(row[column]))
+	}
+	return e.w.WriteRow(record)
+}
+
+func newExportWriter(format string, w io.Writer, sheetName string) (exportWriter, error) {
+	switch format {
+	case ExportCSV:
+		return &csvExportWriter{w: csv.NewWriter(w)}, nil
+	case ExportNDJSON:
+		return &ndjsonExportWriter{w: w, enc: json.NewEncoder(w)}, nil
+	default:
+		xw, err := utils.NewXLSXWriter(w, sheetName)
+		if err != nil {
+			return nil, err
+		}
+		return &xlsxExportWriter{w: xw}, nil
+	}
+}
+
+func exportResources(c *gin.Context) {
+	dbInfo, exists := c.Get(consts.DBInfoContextKey)
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid branch node name",
+			"details": "Export must target exactly one slave node",
+		})
+		return
+	}
+	server := dbInfo.(mm.JumpServer)
+
+	format := c.DefaultQuery("format", ExportCSV)
+	if _, ok := exportContentTypes[format]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid param format",
+			"details": "Param format must be csv, ndjson or xlsx",
+		})
+		return
+	}
+	if ranking, _ := strconv.ParseBool(c.Query("ranking")); ranking {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid filter",
+			"details": "ranking is not supported by export",
+		})
+		return
+	}
+
+	handler, err := newResourcesHandler(c, server)
+	if err != nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "Database init failed", "details": "Database init failed",
+		})
+		return
+	}
+	handler.processedParams = map[string]bool{
+		"m_type": true, "search": true, "format": true, "fields": true, "expand": true,
+	}
+
+	resourceType := c.Query("m_type")
+	source, err := handler.exportSource(c, resourceType)
+	var filterErr *FilterError
+	if errors.As(err, &filterErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter", "details": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Database error", "details": err.Error()})
+		return
+	}
+
+	columns := splitParam(c.Query("fields"))
+	if len(columns) == 0 {
+		columns = exportColumns[resourceType]
+		if columns == nil {
+			columns = exportColumns[Asset]
+		}
+	}
+
+	filename := fmt.Sprintf("%s-%s-%s.%s", resourceType, server.Name, time.Now().Format("20060102150405"), format)
+	c.Header("Content-Type", exportContentTypes[format])
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	writer, err := newExportWriter(format, c.Writer, resourceType)
+	if err == nil {
+		err = writer.WriteHeader(columns)
+	}
+	if err == nil {
+		err = source.query.FindInBatches(source.dest, exportBatchSize, func(tx *gorm.DB, batch int) error {
+			data, err := json.Marshal(source.convert())
+			if err != nil {
+				return err
+			}
+			var rows []map[string]interface{}
+			if err = json.Unmarshal(data, &rows); err != nil {
+				return err
+			}
+			for _, row := range rows {
+				if err = writer.WriteRow(columns, row); err != nil {
+					return err
+				}
+			}
+			return writer.Flush()
+		}).Error
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		// 响应头已经发出，只能中断输出
+		utils.GetLogger().Error("Export %s of %s failed: %v", resourceType, server.Name, err)
+		_ = c.Error(err)
+		c.Abort()
+	}
+}
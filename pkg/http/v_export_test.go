@@ -0,0 +1,84 @@
+package pkg
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFlattenValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{name: "nil", value: nil, want: ""},
+		{name: "string", value: "web-01", want: "web-01"},
+		{name: "bool", value: true, want: "true"},
+		{name: "integer", value: float64(22), want: "22"},
+		{name: "float", value: 1.5, want: "1.5"},
+		{name: "large number", value: float64(1234567890), want: "1234567890"},
+		{name: "protocol", value: map[string]interface{}{"name": "ssh", "port": float64(22)}, want: "ssh/22"},
+		{name: "node", value: map[string]interface{}{"id": "1", "full_value": "/Default/web", "value": "web"},
+			want: "/Default/web"},
+		{name: "label", value: map[string]interface{}{"label": "Linux", "value": "linux"}, want: "Linux"},
+		{name: "named", value: map[string]interface{}{"id": "1", "name": "admins"}, want: "admins"},
+		{name: "user", value: map[string]interface{}{"id": "1", "username": "admin"}, want: "admin"},
+		{name: "null label skipped", value: map[string]interface{}{"label": nil, "id": "1"}, want: "1"},
+		{name: "unknown object", value: map[string]interface{}{"key": "v"}, want: `{"key":"v"}`},
+		{
+			name: "list",
+			value: []interface{}{
+				map[string]interface{}{"name": "ssh", "port": float64(22)},
+				map[string]interface{}{"name": "rdp", "port": float64(3389)},
+			},
+			want: "ssh/22; rdp/3389",
+		},
+		{name: "empty list", value: []interface{}{}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := flattenValue(tt.value); got != tt.want {
+				t.Errorf("flattenValue(%v) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportWriter(t *testing.T) {
+	columns := []string{"name", "protocols", "is_active"}
+	row := map[string]interface{}{
+		"name":      "web, \"01\"",
+		"protocols": []interface{}{map[string]interface{}{"name": "ssh", "port": float64(22)}},
+		"is_active": true,
+		"secret":    "not exported",
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: ExportCSV, want: "name,protocols,is_active\n\"web, \"\"01\"\"\",ssh/22,true\n"},
+		{format: ExportNDJSON, want: `{"is_active":"true","name":"web, \"01\"","protocols":"ssh/22"}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := newExportWriter(tt.format, &buf, "hosts")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = w.WriteHeader(columns); err != nil {
+				t.Fatal(err)
+			}
+			if err = w.WriteRow(columns, row); err != nil {
+				t.Fatal(err)
+			}
+			if err = w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("export = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,615 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"middleman/pkg/consts"
+	"middleman/pkg/database/models"
+	mm "middleman/pkg/middleware/models"
+	"middleman/pkg/utils"
+)
+
+const (
+	ImportValidate = "validate"
+	ImportCommit   = "commit"
+
+	importMaxSize = 20 << 20
+)
+
+// 未指定角色时绑定 JumpServer 内置的 User 与 OrgUser 角色
+var defaultImportRoleIds = []string{
+	"00000000-0000-0000-0000-000000000003",
+	"00000000-0000-0000-0000-000000000007",
+}
+
+type importColumnSet struct {
+	required []string
+	optional []string
+}
+
+var importColumns = map[string]importColumnSet{
+	User: {
+		required: []string{"username", "name", "email"},
+		optional: []string{
+			"source", "is_active", "phone", "comment", "date_expired", "groups", "roles",
+		},
+	},
+	Host: {
+		required: []string{"name", "address", "platform", "protocols"},
+		optional: []string{"nodes", "is_active", "comment"},
+	},
+}
+
+type ImportRowError struct {
+	Row    int      `json:"row"`
+	Errors []string `json:"errors"`
+}
+
+type importReport struct {
+	Mode   string           `json:"mode"`
+	Total  int              `json:"total"`
+	Valid  int              `json:"valid"`
+	Errors []ImportRowError `json:"errors"`
+}
+
+// importRow 文件中的一行，Line 为文件中的行号（表头为第 1 行），
+// FromXLSX 为 true 时日期列可能是 Excel 的序列号
+type importRow struct {
+	Line     int
+	Values   map[string]string
+	FromXLSX bool
+	errors   []string
+}
+
+func (r *importRow) Get(column string) string {
+	return r.Values[column]
+}
+
+func (r *importRow) addError(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// readImportRecords 返回文件中的全部行，以及文件是否为 xlsx
+func readImportRecords(c *gin.Context) ([][]string, bool, error) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return nil, false, fmt.Errorf("form field file is required")
+	}
+	if fileHeader.Size > importMaxSize {
+		return nil, false, fmt.Errorf("file is larger than %d bytes", importMaxSize)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, false, err
+	}
+	switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+	case ".csv":
+		reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		return records, false, err
+	case ".xlsx":
+		records, err := utils.ReadXLSX(bytes.NewReader(content), int64(len(content)))
+		return records, true, err
+	default:
+		return nil, false, fmt.Errorf("only .csv and .xlsx files are supported")
+	}
+}
+
+func parseImportRows(records [][]string, columns importColumnSet, fromXLSX bool) ([]*importRow, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+	allowed := make(map[string]bool)
+	for _, column := range append(columns.required, columns.optional...) {
+		allowed[column] = true
+	}
+	header := make([]string, len(records[0]))
+	seen := make(map[string]bool)
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !allowed[name] {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		header[i], seen[name] = name, true
+	}
+	for _, column := range columns.required {
+		if !seen[column] {
+			return nil, fmt.Errorf("missing column %q", column)
+		}
+	}
+
+	var rows []*importRow
+	for i, record := range records[1:] {
+		row := &importRow{Line: i + 2, Values: make(map[string]string), FromXLSX: fromXLSX}
+		empty := true
+		for j, value := range record {
+			if j >= len(header) || header[j] == "" {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			row.Values[header[j]] = value
+			empty = empty && value == ""
+		}
+		if empty {
+			continue
+		}
+		for _, column := range columns.required {
+			if row.Get(column) == "" {
+				row.addError("%s is required", column)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseImportBool(row *importRow, column string, defaultValue bool) bool {
+	text := row.Get(column)
+	if text == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(text)
+	if err != nil {
+		row.addError("%s must be a boolean", column)
+		return defaultValue
+	}
+	return value
+}
+
+// Excel 日期序列号的合理范围，对应 1970-01-01 至 9999-12-31
+const (
+	excelSerialMin = 25569
+	excelSerialMax = 2958465
+)
+
+// parseImportTime 兼容 xlsx 中以序列号保存的日期，csv 中的数字不会被当作日期
+func parseImportTime(text string, fromXLSX bool) (time.Time, error) {
+	if serial, err := strconv.ParseFloat(text, 64); err == nil {
+		if !fromXLSX || serial < excelSerialMin || serial > excelSerialMax {
+			return time.Time{}, fmt.Errorf("invalid time: %s", text)
+		}
+		base := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+		return base.Add(time.Duration(serial * 24 * float64(time.Hour))), nil
+	}
+	return models.ParseTime(text)
+}
+
+func splitImportNames(text string) []string {
+	var names []string
+	for _, name := range strings.FieldsFunc(text, func(r rune) bool { return r == ';' || r == ',' }) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (h *ResourcesHandler) prepareImportUsers(rows []*importRow, operator string) ([]models.User, error) {
+	var usernames, emails, groupNames, roleNames []string
+	for _, row := range rows {
+		usernames = append(usernames, row.Get("username"))
+		emails = append(emails, strings.ToLower(row.Get("email")))
+		groupNames = append(groupNames, splitImportNames(row.Get("groups"))...)
+		roleNames = append(roleNames, splitImportNames(row.Get("roles"))...)
+	}
+
+	var existUsers []models.User
+	if err := h.db.Select("username", "email").
+		Where("username IN ? OR LOWER(email) IN ?", usernames, emails).
+		Find(&existUsers).Error; err != nil {
+		return nil, err
+	}
+	existUsernames, existEmails := make(map[string]bool), make(map[string]bool)
+	for _, user := range existUsers {
+		existUsernames[user.Username], existEmails[strings.ToLower(user.Email)] = true, true
+	}
+
+	groups := make(map[string]models.UserGroup)
+	if len(groupNames) > 0 {
+		var found []models.UserGroup
+		if err := h.db.Where("name IN ? AND org_id = ?", groupNames, h.orgID).
+			Find(&found).Error; err != nil {
+			return nil, err
+		}
+		for _, group := range found {
+			groups[group.Name] = group
+		}
+	}
+	var foundRoles, defaultRoles []models.RbacRole
+	if err := h.db.Where("name IN ? OR id IN ?", append(roleNames, ""), defaultImportRoleIds).
+		Find(&foundRoles).Error; err != nil {
+		return nil, err
+	}
+	roles := make(map[string]models.RbacRole)
+	for _, role := range foundRoles {
+		roles[role.Name] = role
+		for _, id := range defaultImportRoleIds {
+			if role.ID == id {
+				defaultRoles = append(defaultRoles, role)
+			}
+		}
+	}
+
+	now := &models.UTCTime{Time: time.Now()}
+	fileUsernames, fileEmails := make(map[string]int), make(map[string]int)
+	users := make([]models.User, 0, len(rows))
+	for _, row := range rows {
+		username, email := row.Get("username"), strings.ToLower(row.Get("email"))
+		if username != "" {
+			if line, exists := fileUsernames[username]; exists {
+				row.addError("username %s duplicates row %d", username, line)
+			} else if existUsernames[username] {
+				row.addError("username %s already exists", username)
+			} else {
+				fileUsernames[username] = row.Line
+			}
+		}
+		if email != "" {
+			if _, err := mail.ParseAddress(email); err != nil {
+				row.addError("email %s is invalid", email)
+			} else if line, exists := fileEmails[email]; exists {
+				row.addError("email %s duplicates row %d", email, line)
+			} else if existEmails[email] {
+				row.addError("email %s already exists", email)
+			} else {
+				fileEmails[email] = row.Line
+			}
+		}
+
+		dateExpired := time.Now().AddDate(70, 0, 0)
+		if text := row.Get("date_expired"); text != "" {
+			t, err := parseImportTime(text, row.FromXLSX)
+			if err != nil {
+				row.addError("date_expired %s is invalid", text)
+			}
+			dateExpired = t
+		}
+
+		var userGroups []models.UserGroup
+		for _, name := range splitImportNames(row.Get("groups")) {
+			if group, exists := groups[name]; exists {
+				userGroups = append(userGroups, group)
+			} else {
+				row.addError("user group %s not found", name)
+			}
+		}
+		userRoles := defaultRoles
+		if names := splitImportNames(row.Get("roles")); len(names) > 0 {
+			userRoles = nil
+			for _, name := range names {
+				if role, exists := roles[name]; exists {
+					userRoles = append(userRoles, role)
+				} else {
+					row.addError("role %s not found", name)
+				}
+			}
+		}
+
+		source := row.Get("source")
+		if source == "" {
+			source = "local"
+		}
+		users = append(users, models.User{
+			ID: uuid.New().String(), Username: username, Name: row.Get("name"), Email: email,
+			IsActive: parseImportBool(row, "is_active", true), Comment: row.Get("comment"),
+			Phone: row.Get("phone"), Source: source, IsFirstLogin: true, NeedUpdatePassword: true,
+			CreatedBy: operator, UpdatedBy: operator,
+			DateJoined: now, DateUpdated: now, DateExpired: &models.UTCTime{Time: dateExpired},
+			Roles: userRoles, UserGroups: userGroups,
+		})
+	}
+	return users, nil
+}
+
+func (h *ResourcesHandler) prepareImportHosts(rows []*importRow, operator string) ([]models.Host, error) {
+	var names, platformNames, nodeNames []string
+	for _, row := range rows {
+		names = append(names, row.Get("name"))
+		platformNames = append(platformNames, row.Get("platform"))
+		nodeNames = append(nodeNames, splitImportNames(row.Get("nodes"))...)
+	}
+
+	var existAssets []models.Asset
+	if err := h.db.Select("name").Where("name IN ? AND org_id = ?", names, h.orgID).
+		Find(&existAssets).Error; err != nil {
+		return nil, err
+	}
+	existNames := make(map[string]bool)
+	for _, asset := range existAssets {
+		existNames[asset.Name] = true
+	}
+
+	var platforms []models.Platform
+	if err := h.db.Where("name IN ?", platformNames).Find(&platforms).Error; err != nil {
+		return nil, err
+	}
+	platformIds := make(map[string]uint)
+	for _, platform := range platforms {
+		platformIds[platform.Name] = platform.ID
+	}
+
+	// 节点可以使用完整路径或节点名，节点名重复时必须使用完整路径
+	nodeIds := make(map[string][]string)
+	if len(nodeNames) > 0 {
+		var nodes []models.Node
+		if err := h.db.Where("(full_value IN ? OR value IN ?) AND org_id = ?",
+			nodeNames, nodeNames, h.orgID).Find(&nodes).Error; err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			nodeIds[node.FullValue] = []string{node.ID}
+		}
+		for _, node := range nodes {
+			if node.Value != node.FullValue {
+				nodeIds[node.Value] = append(nodeIds[node.Value], node.ID)
+			}
+		}
+	}
+
+	now := &models.UTCTime{Time: time.Now()}
+	fileNames := make(map[string]int)
+	hosts := make([]models.Host, 0, len(rows))
+	for _, row := range rows {
+		name := row.Get("name")
+		if name != "" {
+			if line, exists := fileNames[name]; exists {
+				row.addError("name %s duplicates row %d", name, line)
+			} else if existNames[name] {
+				row.addError("name %s already exists", name)
+			} else {
+				fileNames[name] = row.Line
+			}
+		}
+
+		platformID, exists := platformIds[row.Get("platform")]
+		if !exists && row.Get("platform") != "" {
+			row.addError("platform %s not found", row.Get("platform"))
+		}
+
+		var protocols models.ProtocolArray
+		for _, text := range splitImportNames(row.Get("protocols")) {
+			parts := strings.SplitN(text, "/", 2)
+			port, err := strconv.ParseInt(strings.TrimSpace(parts[len(parts)-1]), 10, 64)
+			if len(parts) != 2 || err != nil || port <= 0 || port > 65535 {
+				row.addError("protocol %s must be like ssh/22", text)
+				continue
+			}
+			protocols = append(protocols, models.Protocol{Name: strings.TrimSpace(parts[0]), Port: port})
+		}
+
+		var assetNodeIds []string
+		for _, nodeName := range splitImportNames(row.Get("nodes")) {
+			switch ids := nodeIds[nodeName]; len(ids) {
+			case 0:
+				row.addError("node %s not found", nodeName)
+			case 1:
+				assetNodeIds = append(assetNodeIds, ids[0])
+			default:
+				row.addError("node %s is ambiguous, use the full path", nodeName)
+			}
+		}
+
+		id := uuid.New().String()
+		hosts = append(hosts, models.Host{
+			AssetPtrID: id,
+			Asset: models.Asset{
+				ID: id, Name: name, Address: row.Get("address"),
+				IsActive: parseImportBool(row, "is_active", true), Comment: row.Get("comment"),
+				OrgID: h.orgID, PlatformID: platformID, Connectivity: "-",
+				CreatedBy: operator, UpdatedBy: operator, DateCreated: now, DateUpdated: now,
+				Protocols: protocols, NodeIds: assetNodeIds,
+			},
+		})
+	}
+	return hosts, nil
+}
+
+func (h *ResourcesHandler) commitImportUsers(users []models.User) ([]string, error) {
+	var ids []string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		for i := range users {
+			user := users[i]
+			var roleBindings []models.RbacRoleBinding
+			for _, role := range user.Roles {
+				roleBindings = append(roleBindings, models.RbacRoleBinding{
+					ID:    uuid.New().String(),
+					Scope: role.Scope, UserID: user.ID, RoleID: role.ID,
+					CreatedBy: user.CreatedBy, UpdatedBy: user.UpdatedBy,
+					OrgID: h.bindingOrgID(role.Scope),
+				})
+			}
+			user.Roles = nil
+			if err := tx.Create(&user).Error; err != nil {
+				return fmt.Errorf("create user %s failed: %w", user.Username, err)
+			}
+			// is_active 带有默认值，false 不会被 Create 写入
+			if !user.IsActive {
+				if err := tx.Model(&user).UpdateColumn("is_active", false).Error; err != nil {
+					return err
+				}
+			}
+			if len(roleBindings) > 0 {
+				if err := tx.Create(&roleBindings).Error; err != nil {
+					return err
+				}
+			}
+			ids = append(ids, user.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		go h.jmsClient.CreateUser(user.ToJMSUser())
+	}
+	return ids, nil
+}
+
+func (h *ResourcesHandler) commitImportHosts(hosts []models.Host) ([]string, error) {
+	type Relation struct {
+		AssetID string `gorm:"column:asset_id"`
+		NodeID  string `gorm:"column:node_id"`
+	}
+
+	var ids []string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var relations []Relation
+		for i := range hosts {
+			host := hosts[i]
+			if err := tx.Create(&host).Error; err != nil {
+				return fmt.Errorf("create host %s failed: %w", host.Asset.Name, err)
+			}
+			if !host.Asset.IsActive {
+				if err := tx.Model(&host.Asset).UpdateColumn("is_active", false).Error; err != nil {
+					return err
+				}
+			}
+			for _, nodeID := range host.Asset.NodeIds {
+				relations = append(relations, Relation{NodeID: nodeID, AssetID: host.AssetPtrID})
+			}
+			ids = append(ids, host.AssetPtrID)
+		}
+		if len(relations) == 0 {
+			return nil
+		}
+		return tx.Table("assets_asset_nodes").CreateInBatches(relations, 100).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range hosts {
+		go h.jmsClient.CreateAsset(&hosts[i])
+	}
+	return ids, nil
+}
+
+func importResources(c *gin.Context) {
+	dbInfo, exists := c.Get(consts.DBInfoContextKey)
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid branch node name",
+			"details": "Import must target exactly one slave node",
+		})
+		return
+	}
+
+	resourceType := c.Query("m_type")
+	columns, ok := importColumns[resourceType]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request type",
+			"details": fmt.Sprintf("Invalid request type: %s", resourceType),
+		})
+		return
+	}
+	mode := c.DefaultQuery("mode", ImportValidate)
+	if mode != ImportValidate && mode != ImportCommit {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid param mode",
+			"details": "Param mode must be validate or commit",
+		})
+		return
+	}
+
+	records, fromXLSX, err := readImportRecords(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import file", "details": err.Error()})
+		return
+	}
+	rows, err := parseImportRows(records, columns, fromXLSX)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import file", "details": err.Error()})
+		return
+	}
+
+	handler, err := newResourcesHandler(c, dbInfo.(mm.JumpServer))
+	if err != nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "Database init failed", "details": "Database init failed",
+		})
+		return
+	}
+
+	authServer := c.MustGet(consts.AuthDBInfoContextKey).(mm.JumpServer)
+	operator := string(authServer.Name)
+	var users []models.User
+	var hosts []models.Host
+	switch resourceType {
+	case User:
+		users, err = handler.prepareImportUsers(rows, operator)
+	case Host:
+		hosts, err = handler.prepareImportHosts(rows, operator)
+	}
+	if err != nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Database error", "details": err.Error()})
+		return
+	}
+
+	report := importReport{Mode: mode, Total: len(rows), Errors: []ImportRowError{}}
+	for _, row := range rows {
+		if len(row.errors) > 0 {
+			report.Errors = append(report.Errors, ImportRowError{Row: row.Line, Errors: row.errors})
+		}
+	}
+	report.Valid = report.Total - len(report.Errors)
+	if mode == ImportValidate {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	// 任意一行校验失败时整个文件都不写入
+	if len(report.Errors) > 0 || len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, report)
+		return
+	}
+
+	var ids []string
+	switch resourceType {
+	case User:
+		ids, err = handler.commitImportUsers(users)
+	case Host:
+		ids, err = handler.commitImportHosts(hosts)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   fmt.Sprintf("Failed to import resource: %v", err.Error()),
+			"details": "Database operation failed",
+		})
+		return
+	}
+
+	cache := utils.GetCache()
+	for _, id := range ids {
+		_ = cache.Set(fmt.Sprintf("%s-%s", resourceType, id), "", 0)
+	}
+	c.JSON(http.StatusCreated, report)
+}
@@ -0,0 +1,211 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+	"time"
+
+	"middleman/pkg/utils"
+)
+
+func TestParseImportRows(t *testing.T) {
+	columns := importColumns[User]
+
+	tests := []struct {
+		name       string
+		records    [][]string
+		wantValues []map[string]string
+		wantLines  []int
+		wantErrors [][]string
+		wantErr    bool
+	}{
+		{
+			name: "normalized header",
+			records: [][]string{
+				{" Username ", "NAME", "email", ""},
+				{" admin ", "Admin", "admin@example.com", "ignored"},
+			},
+			wantValues: []map[string]string{{"username": "admin", "name": "Admin", "email": "admin@example.com"}},
+			wantLines:  []int{2},
+			wantErrors: [][]string{nil},
+		},
+		{
+			name: "blank rows skipped and short rows padded",
+			records: [][]string{
+				{"username", "name", "email", "phone"},
+				{"", " ", "", ""},
+				{"bob", "Bob"},
+			},
+			wantValues: []map[string]string{{"username": "bob", "name": "Bob"}},
+			wantLines:  []int{3},
+			wantErrors: [][]string{{"email is required"}},
+		},
+		{
+			name:       "header only",
+			records:    [][]string{{"username", "name", "email"}},
+			wantValues: nil,
+		},
+		{name: "empty file", records: nil, wantErr: true},
+		{name: "unknown column", records: [][]string{{"username", "name", "email", "password"}}, wantErr: true},
+		{name: "duplicate column", records: [][]string{{"username", "name", "email", "Email"}}, wantErr: true},
+		{name: "missing column", records: [][]string{{"username", "name"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := parseImportRows(tt.records, columns, true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseImportRows() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(rows) != len(tt.wantValues) {
+				t.Fatalf("parseImportRows() returned %d rows, want %d", len(rows), len(tt.wantValues))
+			}
+			for i, row := range rows {
+				if !row.FromXLSX {
+					t.Errorf("row %d FromXLSX = false, want true", i)
+				}
+				if row.Line != tt.wantLines[i] {
+					t.Errorf("row %d Line = %d, want %d", i, row.Line, tt.wantLines[i])
+				}
+				if !reflect.DeepEqual(row.Values, tt.wantValues[i]) {
+					t.Errorf("row %d Values = %v, want %v", i, row.Values, tt.wantValues[i])
+				}
+				if !reflect.DeepEqual(row.errors, tt.wantErrors[i]) {
+					t.Errorf("row %d errors = %v, want %v", i, row.errors, tt.wantErrors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseImportTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		fromXLSX bool
+		want     time.Time
+		wantErr  bool
+	}{
+		{name: "date", text: "2024-01-01", want: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "serial from xlsx", text: "45292", fromXLSX: true, want: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "serial with time", text: "45292.5", fromXLSX: true,
+			want: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{name: "minimum serial", text: "25569", fromXLSX: true, want: time.Unix(0, 0).UTC()},
+		{name: "serial from csv", text: "45292", wantErr: true},
+		{name: "serial too small", text: "1", fromXLSX: true, wantErr: true},
+		{name: "serial too large", text: "2958466", fromXLSX: true, wantErr: true},
+		{name: "negative serial", text: "-45292", fromXLSX: true, wantErr: true},
+		{name: "not a time", text: "tomorrow", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseImportTime(tt.text, tt.fromXLSX)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseImportTime(%q) error = %v, wantErr %v", tt.text, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseImportTime(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitImportNames(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "", want: nil},
+		{text: "admins", want: []string{"admins"}},
+		{text: "admins; ops", want: []string{"admins", "ops"}},
+		{text: "admins,ops;;dev ,", want: []string{"admins", "ops", "dev"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := splitImportNames(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitImportNames(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// 以 fields 指定导入所需的列导出后，文件可以直接再导入，关联字段导出为名称并以分号分隔
+func TestExportImportRoundTrip(t *testing.T) {
+	columns := []string{"username", "name", "email", "is_active", "groups"}
+	exported := []map[string]interface{}{
+		{
+			"username":  "admin",
+			"name":      "Admin, \"root\"",
+			"email":     "admin@example.com",
+			"is_active": true,
+			"groups": []interface{}{
+				map[string]interface{}{"id": "1", "name": "admins"},
+				map[string]interface{}{"id": "2", "name": "ops"},
+			},
+		},
+		{"username": "bob", "name": "Bob", "email": "bob@example.com", "is_active": false},
+	}
+	want := []map[string]string{
+		{
+			"username": "admin", "name": "Admin, \"root\"", "email": "admin@example.com",
+			"is_active": "true", "groups": "admins; ops",
+		},
+		{
+			"username": "bob", "name": "Bob", "email": "bob@example.com",
+			"is_active": "false", "groups": "",
+		},
+	}
+
+	for _, format := range []string{ExportCSV, ExportXLSX} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := newExportWriter(format, &buf, "users")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = w.WriteHeader(columns); err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range exported {
+				if err = w.WriteRow(columns, row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			var records [][]string
+			if format == ExportXLSX {
+				records, err = utils.ReadXLSX(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			} else {
+				records, err = csv.NewReader(&buf).ReadAll()
+			}
+			if err != nil {
+				t.Fatalf("read %s error = %v", format, err)
+			}
+			rows, err := parseImportRows(records, importColumns[User], format == ExportXLSX)
+			if err != nil {
+				t.Fatalf("parseImportRows() error = %v", err)
+			}
+			if len(rows) != len(want) {
+				t.Fatalf("parseImportRows() returned %d rows, want %d", len(rows), len(want))
+			}
+			for i, row := range rows {
+				if !reflect.DeepEqual(row.Values, want[i]) {
+					t.Errorf("row %d = %v, want %v", i, row.Values, want[i])
+				}
+				if len(row.errors) > 0 {
+					t.Errorf("row %d errors = %v", i, row.errors)
+				}
+			}
+			if got := splitImportNames(rows[0].Get("groups")); !reflect.DeepEqual(got, []string{"admins", "ops"}) {
+				t.Errorf("groups = %q, want [admins ops]", got)
+			}
+			if active := parseImportBool(rows[1], "is_active", true); active {
+				t.Errorf("is_active = %v, want false", active)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package pkg
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"strconv"
 	"strings"
 
@@ -35,47 +36,85 @@ type TreeNode struct {
 	Meta     TreeNodeMeta `json:"meta"`
 }
 
-func (h *ResourcesHandler) getNodes(c *gin.Context, limit, offset int) (interface{}, int64, error) {
-	var err error
-	var nodes []models.Node
-	queryFields := map[string]bool{
-		"id":         true,
-		"key":        true,
-		"value":      true,
-		"parent_key": true,
-		"full_value": true,
-	}
-	q := h.db.Model(&models.Node{})
-	for key, values := range c.Request.URL.Query() {
-		if h.processedParams[key] || !queryFields[key] {
-			continue
-		}
+var nodeFilterFields = FilterFields{
+	"id":           ExactField,
+	"key":          TextField,
+	"value":        TextField,
+	"parent_key":   TextField,
+	"full_value":   TextField,
+	"date_created": TimeField,
+}
 
-		if len(values) > 0 {
-			q = q.Where(fmt.Sprintf("%s = ?", key), values[len(values)-1])
-		}
+func (h *ResourcesHandler) nodeQuery(c *gin.Context) (*gorm.DB, error) {
+	var err error
+	q := h.orgScope(h.db.Model(&models.Node{}), "assets_node")
+	if q, err = h.handleFilter(c, q, "assets_node", nodeFilterFields); err != nil {
+		return nil, err
 	}
 
-	searchFields := []string{"value", "full_value"}
+	searchFields := []string{"assets_node.value", "assets_node.full_value"}
 	q = h.handleSearch(c, q, searchFields)
+	return q, nil
+}
 
-	var count int64
-	if err = q.Count(&count).Limit(limit).Offset(offset).Find(&nodes).Error; err != nil {
+func (h *ResourcesHandler) getNodes(c *gin.Context, limit, offset int) (interface{}, int64, error) {
+	var nodes []models.Node
+	q, err := h.nodeQuery(c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := h.countPage(c, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	if q, err = h.handleFields(c, q, "assets_node", &models.Node{}, []string{"id"}); err != nil {
+		return nil, 0, err
+	}
+	if q, err = h.handlePage(c, q, "assets_node", nodeFilterFields, limit, offset); err != nil {
 		return nil, 0, err
 	}
+	if err = q.Find(&nodes).Error; err != nil {
+		return nil, 0, err
+	}
+	h.setNextCursor(nodes)
 	return nodes, count, nil
 }
 
+func (h *ResourcesHandler) getNode(id string) (interface{}, error) {
+	var node models.Node
+	if err := h.db.Where("id = ? AND org_id = ?", id, h.orgID).First(&node).Error; err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// orgRootKey 默认组织的根节点为 1，其余组织使用组织内没有父节点的节点
+func (h *ResourcesHandler) orgRootKey() string {
+	if h.orgID == models.DefaultOrgID {
+		return DefaultNodeKey
+	}
+	var root models.Node
+	h.db.Select("key").Where("org_id = ? AND parent_key = ?", h.orgID, "").Limit(1).Find(&root)
+	if root.Key == "" {
+		return DefaultNodeKey
+	}
+	return root.Key
+}
+
 func (h *ResourcesHandler) getChildrenNodes(c *gin.Context) (interface{}, int64, error) {
 	var err error
 	var nodes []models.Node
 
-	q := h.db.Model(&models.Node{})
-	searchFields := []string{"value", "full_value"}
+	q := h.orgScope(h.db.Model(&models.Node{}), "assets_node")
+	searchFields := []string{"assets_node.value", "assets_node.full_value"}
 	q = h.handleSearch(c, q, searchFields)
 
 	if c.Query("search") == "" {
-		queryKey := c.DefaultQuery("key", DefaultNodeKey)
+		queryKey := c.Query("key")
+		if queryKey == "" {
+			queryKey = h.orgRootKey()
+		}
 		q = q.Where("key = ? OR parent_key = ?", queryKey, queryKey)
 	}
 	if err = q.Find(&nodes).Error; err != nil {
@@ -110,7 +149,7 @@ func (h *ResourcesHandler) updateNode(c *gin.Context, id string) (err error) {
 		return err
 	}
 
-	if err = h.db.Model(models.Node{}).Where("id = ?", id).
+	if err = h.db.Model(models.Node{}).Where("id = ? AND org_id = ?", id, h.orgID).
 		Update("value", req.Value).Error; err != nil {
 		return err
 	}
@@ -132,9 +171,13 @@ func (h *ResourcesHandler) saveChildrenNode(c *gin.Context) (ids []string, err e
 
 	for _, node := range nodes {
 		var pNode models.Node
-		if err = h.db.Model(pNode).Where("id = ?", node.ParentID).Find(&pNode).Error; err != nil {
+		if err = h.db.Model(pNode).Where("id = ? AND org_id = ?", node.ParentID, h.orgID).
+			Find(&pNode).Error; err != nil {
 			return nil, err
 		}
+		if pNode.ID == "" {
+			return nil, fmt.Errorf("parent node %s does not exist", node.ParentID)
+		}
 
 		var cNodes []models.Node
 		if err = h.db.Model(pNode).Where("parent_key = ?", pNode.Key).Find(&cNodes).Error; err != nil {
@@ -178,7 +221,7 @@ func (h *ResourcesHandler) saveChildrenNode(c *gin.Context) (ids []string, err e
 			Key:          fmt.Sprintf("%s:%d", pNode.Key, keySerial),
 			Value:        node.Value,
 			ChildMark:    0,
-			OrgID:        models.DefaultOrgID,
+			OrgID:        h.orgID,
 			AssetsAmount: 0,
 			ParentKey:    pNode.ParentKey,
 			FullValue:    fmt.Sprintf("%s/%s", pNode.FullValue, node.Value),
@@ -201,6 +244,10 @@ func (h *ResourcesHandler) saveNode(c *gin.Context) (err error) {
 		return err
 	}
 	for _, node := range nodes {
+		node.OrgID = h.orgID
+		if err = h.checkResourceOrg(models.Node{}, Node, node.ID); err != nil {
+			return err
+		}
 		var count int64
 		if err = h.db.Model(node).Where("id = ?", node.ID).Count(&count).Error; err != nil {
 			return err
@@ -235,7 +282,8 @@ func (h *ResourcesHandler) assetNodeRelation(c *gin.Context) (err error) {
 	jmsReq.AssetIds = req.AssetIds
 
 	var nodeCount int64
-	err = h.db.Model(models.Node{}).Where("id = ?", req.NodeID).Limit(1).Count(&nodeCount).Error
+	err = h.db.Model(models.Node{}).Where("id = ? AND org_id = ?", req.NodeID, h.orgID).
+		Limit(1).Count(&nodeCount).Error
 	if err != nil {
 		return err
 	}
@@ -244,7 +292,8 @@ func (h *ResourcesHandler) assetNodeRelation(c *gin.Context) (err error) {
 	}
 
 	var assetsCount int64
-	err = h.db.Model(models.Asset{}).Where("id IN ?", jmsReq.AssetIds).Count(&assetsCount).Error
+	err = h.db.Model(models.Asset{}).Where("id IN ? AND org_id = ?", jmsReq.AssetIds, h.orgID).
+		Count(&assetsCount).Error
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"middleman/pkg/consts"
+	"middleman/pkg/database/models"
+)
+
+// requestOrgID 返回 OrgMiddleware 选择的组织，未经过中间件时使用默认组织
+func requestOrgID(c *gin.Context) string {
+	if orgID := c.GetString(consts.OrgContextKey); orgID != "" {
+		return orgID
+	}
+	return models.DefaultOrgID
+}
+
+var orgFilterFields = FilterFields{
+	"id":           ExactField,
+	"name":         TextField,
+	"builtin":      BoolField,
+	"date_created": TimeField,
+}
+
+func (h *ResourcesHandler) orgQuery(c *gin.Context) (*gorm.DB, error) {
+	var err error
+	q := h.db.Model(&models.Organization{})
+	if q, err = h.handleFilter(c, q, "orgs_organization", orgFilterFields); err != nil {
+		return nil, err
+	}
+
+	searchFields := []string{"orgs_organization.name", "orgs_organization.comment"}
+	q = h.handleSearch(c, q, searchFields)
+	return q, nil
+}
+
+func (h *ResourcesHandler) getOrgs(c *gin.Context, limit, offset int) (interface{}, int64, error) {
+	var orgs []models.Organization
+	q, err := h.orgQuery(c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := h.countPage(c, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	if q, err = h.handleFields(c, q, "orgs_organization", &models.Organization{}, []string{"id"}); err != nil {
+		return nil, 0, err
+	}
+	if q, err = h.handlePage(c, q, "orgs_organization", orgFilterFields, limit, offset); err != nil {
+		return nil, 0, err
+	}
+	if err = q.Find(&orgs).Error; err != nil {
+		return nil, 0, err
+	}
+	h.setNextCursor(orgs)
+	return orgs, count, nil
+}
+
+func (h *ResourcesHandler) getOrg(id string) (interface{}, error) {
+	var org models.Organization
+	if err := h.db.Where("id = ?", id).First(&org).Error; err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// saveOrg 组织不受 X-JMS-ORG 限制，已存在时更新名称及备注，推送时也不携带组织
+func (h *ResourcesHandler) saveOrg(c *gin.Context) (ids []string, err error) {
+	var orgs []models.Organization
+	if err = c.ShouldBindJSON(&orgs); err != nil {
+		return nil, err
+	}
+
+	operator := operatorName(c)
+	for _, org := range orgs {
+		if org.Name == "" {
+			return nil, fmt.Errorf("organization name is required")
+		}
+		if org.ID == "" {
+			org.ID = uuid.New().String()
+		}
+		org.Builtin = false
+		org.UpdatedBy = operator
+
+		var current models.Organization
+		err = h.db.Where("id = ?", org.ID).Limit(1).Find(&current).Error
+		if err != nil {
+			return nil, err
+		}
+		if current.ID != "" {
+			if current.Builtin {
+				return nil, fmt.Errorf("builtin organization %s can not be modified", current.Name)
+			}
+			if err = h.db.Model(&current).Select("name", "comment", "updated_by").
+				Updates(&org).Error; err != nil {
+				return nil, err
+			}
+			go h.jmsClient.WithOrg("").UpdateOrg(org.ToJms())
+			continue
+		}
+
+		org.CreatedBy = operator
+		if err = h.db.Create(&org).Error; err != nil {
+			return nil, err
+		}
+		ids = append(ids, org.ID)
+		go h.jmsClient.WithOrg("").CreateOrg(org.ToJms())
+	}
+	return ids, nil
+}
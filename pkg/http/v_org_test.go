@@ -0,0 +1,125 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"middleman/pkg/consts"
+	"middleman/pkg/database/models"
+)
+
+const testOrgID = "1c5b7e5a-3f52-4c1e-9f0e-2b6c8d4a9e10"
+
+func TestRequestOrgID(t *testing.T) {
+	c := newQueryContext("")
+	if got := requestOrgID(c); got != models.DefaultOrgID {
+		t.Errorf("requestOrgID() = %q, want default org", got)
+	}
+	c.Set(consts.OrgContextKey, testOrgID)
+	if got := requestOrgID(c); got != testOrgID {
+		t.Errorf("requestOrgID() = %q, want %q", got, testOrgID)
+	}
+}
+
+func TestOrgScope(t *testing.T) {
+	h := &ResourcesHandler{db: newDryRunDB(t), orgID: testOrgID}
+	sql := h.db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return h.orgScope(tx.Model(&models.Asset{}), "assets").Where("id = ?", "1").Find(&[]models.Asset{})
+	})
+	want := `SELECT * FROM "assets" WHERE assets.org_id = '` + testOrgID + `' AND id = '1'`
+	if sql != want {
+		t.Errorf("orgScope() SQL = %s, want %s", sql, want)
+	}
+}
+
+func TestOrgUsers(t *testing.T) {
+	orgBindings := `SELECT "user_id" FROM "rbac_role_bindings" WHERE scope = 'org' AND org_id = '%s'`
+	tests := []struct {
+		name  string
+		orgID string
+		want  string
+	}{
+		{name: "org", orgID: testOrgID,
+			want: `SELECT * FROM "users" WHERE users.id IN (` + fmt.Sprintf(orgBindings, testOrgID) + `)`},
+		// 没有组织角色绑定的用户属于默认组织
+		{name: "default org", orgID: models.DefaultOrgID,
+			want: `SELECT * FROM "users" WHERE (users.id IN (` + fmt.Sprintf(orgBindings, models.DefaultOrgID) +
+				`) OR users.id NOT IN (SELECT "user_id" FROM "rbac_role_bindings" WHERE scope = 'org'))`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ResourcesHandler{db: newDryRunDB(t), orgID: tt.orgID}
+			sql := h.db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				return h.orgUsers(tx.Model(&models.User{}), "users").Find(&[]models.User{})
+			})
+			if sql != tt.want {
+				t.Errorf("orgUsers() SQL = %s, want %s", sql, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusOf(t *testing.T) {
+	err := newStatusError(http.StatusConflict, "asset %s already exists in another organization", "1")
+	if status, ok := statusOf(err); !ok || status != http.StatusConflict {
+		t.Errorf("statusOf() = %d, %v, want %d", status, ok, http.StatusConflict)
+	}
+	if err.Error() != "asset 1 already exists in another organization" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	if _, ok := statusOf(errors.New("database error")); ok {
+		t.Error("statusOf() reports a status for a plain error")
+	}
+}
+
+// newOrgLookupDB 查询资源所属组织时返回 orgIDs
+func newOrgLookupDB(t *testing.T, orgIDs []string, queries *[]string) *gorm.DB {
+	t.Helper()
+	db := newDryRunDB(t)
+	err := db.Callback().Query().After("gorm:after_query").Register("test:org", func(tx *gorm.DB) {
+		if dest, ok := tx.Statement.Dest.(*[]string); ok {
+			*queries = append(*queries, tx.Statement.SQL.String())
+			*dest = append(*dest, orgIDs...)
+			tx.RowsAffected = int64(len(orgIDs))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestCheckResourceOrg(t *testing.T) {
+	tests := []struct {
+		name       string
+		orgIDs     []string
+		wantStatus int
+	}{
+		{name: "new resource"},
+		{name: "same org", orgIDs: []string{testOrgID}},
+		{name: "other org", orgIDs: []string{models.DefaultOrgID}, wantStatus: http.StatusConflict},
+	}
+	for _, model := range []interface{}{models.Asset{}, models.AssetPermission{}, models.UserGroup{}, models.Node{}} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%T %s", model, tt.name), func(t *testing.T) {
+				var queries []string
+				h := &ResourcesHandler{db: newOrgLookupDB(t, tt.orgIDs, &queries), orgID: testOrgID}
+				err := h.checkResourceOrg(model, "resource", "1")
+				status, _ := statusOf(err)
+				if (err != nil) != (tt.wantStatus != 0) || status != tt.wantStatus {
+					t.Fatalf("checkResourceOrg() error = %v, want status %d", err, tt.wantStatus)
+				}
+				// 按 id 查询，不限制组织
+				if len(queries) != 1 || !strings.Contains(queries[0], "WHERE id = $1") ||
+					strings.Contains(queries[0], "org_id =") {
+					t.Errorf("checkResourceOrg() queries = %v", queries)
+				}
+			})
+		}
+	}
+}
@@ -15,32 +15,36 @@ func (h *ResourcesHandler) savePerm(c *gin.Context) (ids []string, err error) {
 		return nil, err
 	}
 	for _, perm := range perms {
-		perm.OrgID = models.DefaultOrgID
+		perm.OrgID = h.orgID
 
 		var users []models.User
 		if len(perm.UserIds) > 0 {
-			h.db.Model(&users).Where("id IN ?", perm.UserIds).Find(&users)
+			h.orgUsers(h.db.Model(&users), "users").Where("id IN ?", perm.UserIds).Find(&users)
 		}
 		perm.Users = users
 
 		var userGroups []models.UserGroup
 		if len(perm.UserGroupIds) > 0 {
-			h.db.Model(&userGroups).Where("id IN ?", perm.UserGroupIds).Find(&userGroups)
+			h.orgScope(h.db.Model(&userGroups), "user_groups").
+				Where("id IN ?", perm.UserGroupIds).Find(&userGroups)
 		}
 		perm.UserGroups = userGroups
 
 		var assets []models.Asset
 		if len(perm.AssetIds) > 0 {
-			h.db.Model(&assets).Where("id IN ?", perm.AssetIds).Find(&assets)
+			h.orgScope(h.db.Model(&assets), "assets").Where("id IN ?", perm.AssetIds).Find(&assets)
 		}
 		perm.Assets = assets
 
 		var nodes []models.Node
 		if len(perm.NodeIds) > 0 {
-			h.db.Model(&nodes).Where("id IN ?", perm.NodeIds).Find(&nodes)
+			h.orgScope(h.db.Model(&nodes), "assets_node").Where("id IN ?", perm.NodeIds).Find(&nodes)
 		}
 		perm.Nodes = nodes
 
+		if err = h.checkResourceOrg(models.AssetPermission{}, Permission, perm.ID); err != nil {
+			return nil, err
+		}
 		var count int64
 		if err = h.db.Model(perm).Where("id = ?", perm.ID).Count(&count).Error; err != nil {
 			return nil, err
@@ -62,55 +66,91 @@ func (h *ResourcesHandler) savePerm(c *gin.Context) (ids []string, err error) {
 	return ids, nil
 }
 
-func (h *ResourcesHandler) getPerms(c *gin.Context, limit, offset int) (interface{}, int64, error) {
+var permFilterFields = FilterFields{
+	"id":           ExactField,
+	"name":         TextField,
+	"is_active":    BoolField,
+	"date_start":   TimeField,
+	"date_expired": TimeField,
+	"date_created": TimeField,
+}
+
+func (h *ResourcesHandler) permQuery(c *gin.Context) (*gorm.DB, error) {
 	var err error
-	var perms []models.AssetPermission
-	queryFields := map[string]bool{
-		"id":        true,
-		"name":      true,
-		"is_active": true,
-	}
-	q := h.db.Model(&models.AssetPermission{}).
-		Preload("Users", func(db *gorm.DB) *gorm.DB {
+	relations := Relations{
+		"users": {Preload: "Users", Keys: []string{"users"}, Scope: func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, name, username")
-		}).
-		Preload("UserGroups", func(db *gorm.DB) *gorm.DB {
+		}},
+		"user_groups": {Preload: "UserGroups", Keys: []string{"user_groups"}, Scope: func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, name")
-		}).
-		Preload("Nodes", func(db *gorm.DB) *gorm.DB {
+		}},
+		"nodes": {Preload: "Nodes", Keys: []string{"nodes"}, Scope: func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, value, full_value")
-		}).
-		Preload("Assets", func(db *gorm.DB) *gorm.DB {
+		}},
+		"assets": {Preload: "Assets", Keys: []string{"assets"}, Scope: func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, name, address")
-		})
-	for key, values := range c.Request.URL.Query() {
-		if h.processedParams[key] || !queryFields[key] {
-			continue
-		}
-
-		if len(values) > 0 {
-			q = q.Where(fmt.Sprintf("%s = ?", key), values[len(values)-1])
-		}
+		}},
+	}
+	q := h.orgScope(h.db.Model(&models.AssetPermission{}), "perms_assetpermission")
+	q, err = h.handleExpand(c, q, relations, []string{"users", "user_groups", "nodes", "assets"})
+	if err != nil {
+		return nil, err
+	}
+	if q, err = h.handleFilter(c, q, "perms_assetpermission", permFilterFields); err != nil {
+		return nil, err
 	}
 
-	searchFields := []string{"name"}
+	searchFields := []string{"perms_assetpermission.name"}
 	q = h.handleSearch(c, q, searchFields)
+	return q, nil
+}
 
-	var count int64
-	if err = q.Count(&count).Limit(limit).Offset(offset).Find(&perms).Error; err != nil {
+func (h *ResourcesHandler) getPerms(c *gin.Context, limit, offset int) (interface{}, int64, error) {
+	var perms []models.AssetPermission
+	q, err := h.permQuery(c)
+	if err != nil {
 		return nil, 0, err
 	}
 
+	count, err := h.countPage(c, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	if q, err = h.handleFields(c, q, "perms_assetpermission", &models.AssetPermission{}, []string{"id", "is_active", "date_start", "date_expired"}); err != nil {
+		return nil, 0, err
+	}
+	if q, err = h.handlePage(c, q, "perms_assetpermission", permFilterFields, limit, offset); err != nil {
+		return nil, 0, err
+	}
+	if err = q.Find(&perms).Error; err != nil {
+		return nil, 0, err
+	}
+	h.setNextCursor(perms)
+
 	for i := range perms {
 		perms[i].Valid = perms[i].IsValid()
 	}
 	return perms, count, nil
 }
 
-func (h *ResourcesHandler) deletePerm(id, cacheKey string) (err error) {
-	err = h.db.Where("id = ?", id).Delete(&models.AssetPermission{}).Error
+func (h *ResourcesHandler) getPerm(id string) (interface{}, error) {
+	var perm models.AssetPermission
+	err := h.db.Preload("Users").Preload("UserGroups").Preload("Nodes").Preload("Assets").
+		Where("id = ? AND org_id = ?", id, h.orgID).First(&perm).Error
 	if err != nil {
-		return err
+		return nil, err
+	}
+	perm.Valid = perm.IsValid()
+	return perm, nil
+}
+
+func (h *ResourcesHandler) deletePerm(id, cacheKey string) (err error) {
+	result := h.db.Where("id = ? AND org_id = ?", id, h.orgID).Delete(&models.AssetPermission{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errResourceNotFound
 	}
 	go h.jmsClient.DeletePerm(id, cacheKey)
 	return nil
@@ -130,8 +170,14 @@ func (h *ResourcesHandler) permRelation(
 
 	var ids []string
 	if len(relationIds) > 0 {
-		err = h.db.Model(&model).Select("id").
-			Where("id IN ?", relationIds).Pluck("id", &ids).Error
+		q := h.db.Model(&model).Select("id").Where("id IN ?", relationIds)
+		// 用户不属于组织，其余关联对象需要与授权在同一组织
+		if _, isUser := model.(models.User); isUser {
+			q = h.orgUsers(q, "users")
+		} else {
+			q = q.Where("org_id = ?", h.orgID)
+		}
+		err = q.Pluck("id", &ids).Error
 		if err != nil {
 			return err
 		}
@@ -170,14 +216,15 @@ func (h *ResourcesHandler) updatePerm(c *gin.Context, id string) (err error) {
 	}
 
 	var count int64
-	if err = h.db.Model(perm).Where("id = ?", id).Limit(1).Count(&count).Error; err != nil {
+	if err = h.db.Model(perm).Where("id = ? AND org_id = ?", id, h.orgID).
+		Limit(1).Count(&count).Error; err != nil {
 		return err
 	}
 	if count != 1 {
 		return fmt.Errorf("permission %s not found", perm.ID)
 	}
 
-	perm.OrgID = models.DefaultOrgID
+	perm.OrgID = h.orgID
 	err = h.db.Transaction(func(tx *gorm.DB) error {
 		if err = h.db.Model(perm).
 			Omit("id", "Users", "UserGroups", "Assets", "Nodes").
@@ -0,0 +1,202 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+
+	"middleman/pkg/database/models"
+)
+
+// uniqueViolationCode Postgres 唯一约束冲突的错误码
+const uniqueViolationCode = "23505"
+
+type respRoleBinding struct {
+	models.RbacRoleBinding
+
+	RoleName  string `json:"role_name"`
+	Username  string `json:"username"`
+	RoleScope string `json:"role_scope"`
+}
+
+func newRespRoleBinding(binding models.RbacRoleBinding) respRoleBinding {
+	return respRoleBinding{
+		RbacRoleBinding: binding,
+		RoleName:        binding.Role.Name, RoleScope: binding.Role.Scope,
+		Username: binding.User.Username,
+	}
+}
+
+var roleBindingFilterFields = FilterFields{
+	"id":           ExactField,
+	"scope":        ExactField,
+	"user_id":      ExactField,
+	"role_id":      ExactField,
+	"date_created": TimeField,
+}
+
+// roleBindingScope 系统角色的绑定在所有组织中可见，组织角色的绑定只在所属组织中可见
+func (h *ResourcesHandler) roleBindingScope(q *gorm.DB) *gorm.DB {
+	return q.Where("(rbac_role_bindings.scope = ? OR (rbac_role_bindings.scope = ? AND rbac_role_bindings.org_id = ?))",
+		models.SystemRoleScope, models.OrgRoleScope, h.orgID)
+}
+
+func preloadBindingRelations(q *gorm.DB) *gorm.DB {
+	return q.Preload("Role", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, name, scope")
+	}).Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, username")
+	})
+}
+
+func (h *ResourcesHandler) roleBindingQuery(c *gin.Context) (*gorm.DB, error) {
+	var err error
+	q := h.roleBindingScope(h.db.Model(&models.RbacRoleBinding{}))
+	if q, err = h.handleFilter(c, q, "rbac_role_bindings", roleBindingFilterFields); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (h *ResourcesHandler) getRoleBindings(c *gin.Context, limit, offset int) (interface{}, int64, error) {
+	var bindings []models.RbacRoleBinding
+	q, err := h.roleBindingQuery(c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := h.countPage(c, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	required := []string{"id", "role_id", "user_id"}
+	if q, err = h.handleFields(c, q, "rbac_role_bindings", &models.RbacRoleBinding{}, required); err != nil {
+		return nil, 0, err
+	}
+	if q, err = h.handlePage(c, q, "rbac_role_bindings", roleBindingFilterFields, limit, offset); err != nil {
+		return nil, 0, err
+	}
+	if err = preloadBindingRelations(q).Find(&bindings).Error; err != nil {
+		return nil, 0, err
+	}
+	h.setNextCursor(bindings)
+
+	newBindings := make([]respRoleBinding, 0, len(bindings))
+	for _, binding := range bindings {
+		newBindings = append(newBindings, newRespRoleBinding(binding))
+	}
+	return newBindings, count, nil
+}
+
+func (h *ResourcesHandler) getRoleBinding(id string) (interface{}, error) {
+	var binding models.RbacRoleBinding
+	err := preloadBindingRelations(h.roleBindingScope(h.db.Model(&models.RbacRoleBinding{}))).
+		Where("rbac_role_bindings.id = ?", id).First(&binding).Error
+	if err != nil {
+		return nil, err
+	}
+	return newRespRoleBinding(binding), nil
+}
+
+// saveRoleBinding 传入 scope 时必须与角色的范围一致，组织角色绑定到当前组织
+func (h *ResourcesHandler) saveRoleBinding(c *gin.Context) (ids []string, err error) {
+	var bindings []models.RbacRoleBinding
+	if err = c.ShouldBindJSON(&bindings); err != nil {
+		return nil, err
+	}
+
+	operator := operatorName(c)
+	requested := make(map[string]bool)
+	for i := range bindings {
+		binding := &bindings[i]
+		if binding.UserID == "" || binding.RoleID == "" {
+			return nil, fmt.Errorf("user_id and role_id are required")
+		}
+
+		var role models.RbacRole
+		if err = h.db.Select("id", "scope").Where("id = ?", binding.RoleID).First(&role).Error; err != nil {
+			return nil, fmt.Errorf("role %s: %w", binding.RoleID, err)
+		}
+		if binding.Scope != "" && binding.Scope != role.Scope {
+			return nil, fmt.Errorf("role %s can not be bound in %s scope", binding.RoleID, binding.Scope)
+		}
+		var userCount int64
+		if err = h.db.Model(&models.User{}).Where("id = ?", binding.UserID).Count(&userCount).Error; err != nil {
+			return nil, err
+		}
+		if userCount == 0 {
+			return nil, fmt.Errorf("user %s does not exist", binding.UserID)
+		}
+
+		binding.ID = uuid.New().String()
+		binding.Scope = role.Scope
+		binding.OrgID = h.bindingOrgID(role.Scope)
+		binding.CreatedBy, binding.UpdatedBy = operator, operator
+
+		key := fmt.Sprintf("%s-%s-%s", binding.UserID, binding.RoleID, binding.OrgID)
+		if requested[key] {
+			return nil, newStatusError(http.StatusBadRequest,
+				"duplicate binding of role %s to user %s", binding.RoleID, binding.UserID)
+		}
+		requested[key] = true
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		for _, binding := range bindings {
+			var count int64
+			if txErr := tx.Model(&models.RbacRoleBinding{}).
+				Where("user_id = ? AND role_id = ? AND org_id = ?", binding.UserID, binding.RoleID, binding.OrgID).
+				Count(&count).Error; txErr != nil {
+				return txErr
+			}
+			if count > 0 {
+				return newStatusError(http.StatusConflict,
+					"role %s is already bound to user %s", binding.RoleID, binding.UserID)
+			}
+		}
+		return tx.Omit("Role", "User").Create(&bindings).Error
+	})
+	// 并发授权时由唯一索引保证不会重复绑定
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+		return nil, newStatusError(http.StatusConflict, "role binding already exists: %s", pgErr.Detail)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, binding := range bindings {
+		ids = append(ids, binding.ID)
+		go h.jmsClient.CreateRoleBinding(binding.Scope, binding.ToJms())
+	}
+	return ids, nil
+}
+
+// deleteRoleBinding 撤销最后一个有效系统管理员的角色时返回 errLastAdmin
+func (h *ResourcesHandler) deleteRoleBinding(id, cacheKey string) (err error) {
+	var binding models.RbacRoleBinding
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if txErr := h.roleBindingScope(tx.Model(&models.RbacRoleBinding{})).
+			Where("id = ?", id).Limit(1).Find(&binding).Error; txErr != nil || binding.ID == "" {
+			return txErr
+		}
+		if txErr := tx.Where("id = ?", id).Delete(&models.RbacRoleBinding{}).Error; txErr != nil {
+			return txErr
+		}
+		wasAdmin := binding.Scope == models.SystemRoleScope && binding.RoleID == models.SystemAdminRoleID
+		return checkAdminRemains(tx, wasAdmin)
+	})
+	if err != nil {
+		return err
+	}
+	if binding.ID == "" {
+		return errResourceNotFound
+	}
+	go h.jmsClient.DeleteRoleBinding(binding.Scope, id, cacheKey)
+	return nil
+}
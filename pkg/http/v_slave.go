@@ -0,0 +1,182 @@
+package pkg
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"middleman/pkg/database"
+	"middleman/pkg/middleware/models"
+	"middleman/pkg/utils"
+)
+
+const (
+	KeepDatabase    = "keep"
+	ArchiveDatabase = "archive"
+	DropDatabase    = "drop"
+)
+
+type UpdateSlaveNodeRequest struct {
+	Display      *string `json:"display"`
+	Endpoint     *string `json:"endpoint"`
+	PrivateToken *string `json:"private_token"`
+	// 整体替换节点的标签
+	Labels *models.Labels `json:"labels"`
+}
+
+func findSlaveNode(c *gin.Context) (server models.JumpServer, ok bool) {
+	db := database.GetDBManager().GetDefaultDB()
+	name := c.Param("name")
+	err := db.Model(&models.JumpServer{}).
+		Where("name = ? AND role = ?", name, models.RoleSlave).Find(&server).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error", "details": err.Error(),
+		})
+		return server, false
+	}
+	if server.Name == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Slave node not found",
+			"details": fmt.Sprintf("Slave node %s not found", name),
+		})
+		return server, false
+	}
+	return server, true
+}
+
+func getSlaveNode(c *gin.Context) {
+	server, ok := findSlaveNode(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": server.BaseJumpServer})
+}
+
+func updateSlaveNode(c *gin.Context) {
+	var req UpdateSlaveNodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	server, ok := findSlaveNode(c)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Display != nil {
+		if *req.Display == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "display can not be empty"})
+			return
+		}
+		updates["display"] = *req.Display
+	}
+	if req.Endpoint != nil {
+		if *req.Endpoint == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "endpoint can not be empty"})
+			return
+		}
+		updates["endpoint"] = *req.Endpoint
+	}
+	if req.PrivateToken != nil {
+		if *req.PrivateToken == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "private_token can not be empty"})
+			return
+		}
+		ref, err := server.StorePrivateToken(*req.PrivateToken)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		updates["private_token"] = ref
+	}
+	if req.Labels != nil {
+		if err := req.Labels.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		labels := *req.Labels
+		if labels == nil {
+			labels = models.Labels{}
+		}
+		updates["labels"] = labels
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+		return
+	}
+
+	db := database.GetDBManager().GetDefaultDB()
+	err := db.Model(&models.JumpServer{}).
+		Where("id = ?", server.ID).Updates(updates).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("更新节点失败: %v", err),
+		})
+		return
+	}
+
+	server, ok = findSlaveNode(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": server.BaseJumpServer})
+}
+
+func deregisterSlaveNode(c *gin.Context) {
+	action := c.DefaultQuery("database", KeepDatabase)
+	if action != KeepDatabase && action != ArchiveDatabase && action != DropDatabase {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid param database",
+			"details": "Param database must be one of keep, archive, drop",
+		})
+		return
+	}
+	server, ok := findSlaveNode(c)
+	if !ok {
+		return
+	}
+
+	manager := database.GetDBManager()
+	db := manager.GetDefaultDB()
+	if err := db.Where("id = ?", server.ID).Delete(&models.JumpServer{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("删除节点失败: %v", err),
+		})
+		return
+	}
+
+	if err := utils.DeleteSecret(server.PrivateToken); err != nil {
+		utils.GetLogger().Warn("Delete private token of %s failed: %v", server.Name, err)
+	}
+
+	var err error
+	var archivedName string
+	dbName := string(server.Name)
+	switch action {
+	case KeepDatabase:
+		err = manager.RemoveDB(dbName)
+	case ArchiveDatabase:
+		archivedName, err = manager.ArchiveDatabase(dbName)
+	case DropDatabase:
+		err = manager.DropDatabase(dbName)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   fmt.Sprintf("Slave node %s deregistered, but database %s failed", dbName, action),
+			"details": err.Error(),
+		})
+		return
+	}
+
+	resp := gin.H{
+		"message":  fmt.Sprintf("Slave node %s deregistered successfully", dbName),
+		"database": action,
+	}
+	if archivedName != "" {
+		resp["archived_name"] = archivedName
+	}
+	c.JSON(http.StatusOK, resp)
+}
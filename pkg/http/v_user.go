@@ -1,7 +1,11 @@
 package pkg
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -37,14 +41,14 @@ func (h *ResourcesHandler) saveUser(c *gin.Context) (ids []string, err error) {
 				ID:    uuid.New().String(),
 				Scope: role.Scope, UserID: user.ID, RoleID: role.ID,
 				CreatedBy: user.CreatedBy, UpdatedBy: user.UpdatedBy,
-				OrgID: models.DefaultOrgID,
+				OrgID: h.bindingOrgID(role.Scope),
 			})
 		}
 		user.Roles = nil
 
 		var groups []models.UserGroup
 		if len(groupIds) > 0 {
-			h.db.Model(&groups).Where("id IN ?", groupIds).Find(&groups)
+			h.orgScope(h.db.Model(&groups), "user_groups").Where("id IN ?", groupIds).Find(&groups)
 		}
 		user.UserGroups = groups
 
@@ -108,6 +112,10 @@ func (h *ResourcesHandler) saveUserGroup(c *gin.Context) (err error) {
 		return err
 	}
 	for _, group := range userGroups {
+		group.OrgID = h.orgID
+		if err = h.checkResourceOrg(models.UserGroup{}, UserGroup, group.ID); err != nil {
+			return err
+		}
 		var count int64
 		if err = h.db.Model(group).Where("id = ?", group.ID).Count(&count).Error; err != nil {
 			return err
@@ -126,63 +134,157 @@ func (h *ResourcesHandler) saveUserGroup(c *gin.Context) (err error) {
 	return nil
 }
 
-func (h *ResourcesHandler) getUsers(c *gin.Context, limit, offset int) (interface{}, int64, error) {
-	var err error
-	var users []models.User
-	queryFields := map[string]bool{
-		"id":        true,
-		"username":  true,
-		"name":      true,
-		"email":     true,
-		"source":    true,
-		"is_active": true,
-	}
-	q := h.db.Model(&models.User{}).Preload("Roles").Preload("UserGroups")
-	for key, values := range c.Request.URL.Query() {
-		if h.processedParams[key] || !queryFields[key] {
-			continue
-		}
+type respUser struct {
+	models.User
 
-		if len(values) > 0 {
-			q = q.Where(fmt.Sprintf("%s = ?", key), values[len(values)-1])
+	IsValid     bool              `json:"is_valid"`
+	Roles       []models.RbacRole `json:"-"`
+	OrgRoles    []models.RbacRole `json:"org_roles"`
+	SystemRoles []models.RbacRole `json:"system_roles"`
+}
+
+func newRespUser(user models.User) respUser {
+	var orgRoles, systemRoles []models.RbacRole
+	for _, role := range user.Roles {
+		if role.Scope == "system" {
+			systemRoles = append(systemRoles, role)
+		} else {
+			orgRoles = append(orgRoles, role)
 		}
 	}
+	return respUser{
+		User: user, IsValid: user.IsValid(),
+		OrgRoles: orgRoles, SystemRoles: systemRoles,
+	}
+}
+
+var userFilterFields = FilterFields{
+	"id":           ExactField,
+	"username":     TextField,
+	"name":         TextField,
+	"email":        TextField,
+	"source":       TextField,
+	"is_active":    BoolField,
+	"date_joined":  TimeField,
+	"date_expired": TimeField,
+	"last_login":   TimeField,
+}
+
+// orgUsers 用户不属于组织，组织内的用户为在该组织中有角色绑定的用户。
+// 没有任何组织角色绑定的用户（只有系统角色或没有角色）视为默认组织的成员
+func (h *ResourcesHandler) orgUsers(q *gorm.DB, table string) *gorm.DB {
+	bindings := h.db.Model(&models.RbacRoleBinding{}).Select("user_id").
+		Where("scope = ? AND org_id = ?", models.OrgRoleScope, h.orgID)
+	if h.orgID != models.DefaultOrgID {
+		return q.Where(fmt.Sprintf("%s.id IN (?)", table), bindings)
+	}
+	orgBindings := h.db.Model(&models.RbacRoleBinding{}).Select("user_id").
+		Where("scope = ?", models.OrgRoleScope)
+	return q.Where(fmt.Sprintf("(%s.id IN (?) OR %s.id NOT IN (?))", table, table), bindings, orgBindings)
+}
+
+func (h *ResourcesHandler) userGroupScope(db *gorm.DB) *gorm.DB {
+	return db.Where("org_id = ?", h.orgID)
+}
+
+// bindingOrgID 系统角色的绑定不属于任何组织，仍记录在默认组织下
+func (h *ResourcesHandler) bindingOrgID(scope string) string {
+	if scope == models.SystemRoleScope {
+		return models.DefaultOrgID
+	}
+	return h.orgID
+}
+
+func (h *ResourcesHandler) userQuery(c *gin.Context) (*gorm.DB, error) {
+	var err error
+	relations := Relations{
+		"roles":  {Preload: "Roles", Keys: []string{"org_roles", "system_roles"}},
+		"groups": {Preload: "UserGroups", Scope: h.userGroupScope, Keys: []string{"groups"}},
+	}
+	q := h.orgUsers(h.db.Model(&models.User{}), "users")
+	if q, err = h.handleExpand(c, q, relations, []string{"roles", "groups"}); err != nil {
+		return nil, err
+	}
+	if q, err = h.handleFilter(c, q, "users", userFilterFields); err != nil {
+		return nil, err
+	}
 
-	searchFields := []string{"username", "name", "email"}
+	searchFields := []string{"users.username", "users.name", "users.email"}
 	q = h.handleSearch(c, q, searchFields)
+	return q, nil
+}
 
-	var count int64
-	if err = q.Count(&count).Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+func (h *ResourcesHandler) getUsers(c *gin.Context, limit, offset int) (interface{}, int64, error) {
+	var users []models.User
+	q, err := h.userQuery(c)
+	if err != nil {
 		return nil, 0, err
 	}
 
-	type tmpUser struct {
-		models.User
-
-		IsValid     bool              `json:"is_valid"`
-		Roles       []models.RbacRole `json:"-"`
-		OrgRoles    []models.RbacRole `json:"org_roles"`
-		SystemRoles []models.RbacRole `json:"system_roles"`
+	count, err := h.countPage(c, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	if q, err = h.handleFields(c, q, "users", &models.User{}, []string{"id", "is_active", "date_expired"}); err != nil {
+		return nil, 0, err
 	}
+	if q, err = h.handlePage(c, q, "users", userFilterFields, limit, offset); err != nil {
+		return nil, 0, err
+	}
+	if err = q.Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	h.setNextCursor(users)
 
-	var newUsers []tmpUser
+	var newUsers []respUser
 	for _, user := range users {
-		var orgRoles, systemRoles []models.RbacRole
-		for _, role := range user.Roles {
-			if role.Scope == "system" {
-				systemRoles = append(systemRoles, role)
-			} else {
-				orgRoles = append(orgRoles, role)
-			}
-		}
-		newUsers = append(newUsers, tmpUser{
-			User: user, IsValid: user.IsValid(),
-			OrgRoles: orgRoles, SystemRoles: systemRoles,
-		})
+		newUsers = append(newUsers, newRespUser(user))
 	}
 	return newUsers, count, nil
 }
 
+func (h *ResourcesHandler) getUser(id string) (interface{}, error) {
+	var user models.User
+	err := h.orgUsers(h.db.Preload("Roles").Preload("UserGroups", h.userGroupScope), "users").
+		Where("id = ?", id).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return newRespUser(user), nil
+}
+
+func (h *ResourcesHandler) getRole(id string) (interface{}, error) {
+	var role models.RbacRole
+	if err := h.db.Where("id = ?", id).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func (h *ResourcesHandler) getUserGroup(id string) (interface{}, error) {
+	var group models.UserGroup
+	err := h.db.Preload("Users", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, name, username")
+	}).Where("id = ? AND org_id = ?", id, h.orgID).First(&group).Error
+	if err != nil {
+		return nil, err
+	}
+
+	type groupUser struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Username string `json:"username"`
+	}
+	users := make([]groupUser, 0, len(group.Users))
+	for _, u := range group.Users {
+		users = append(users, groupUser{ID: u.ID, Name: u.Name, Username: u.Username})
+	}
+	return struct {
+		models.UserGroup
+		Users []groupUser `json:"users"`
+	}{UserGroup: group, Users: users}, nil
+}
+
 func (h *ResourcesHandler) unblockUser(id string) error {
 	go h.jmsClient.UnblockUser(id)
 	return nil
@@ -192,3 +294,471 @@ func (h *ResourcesHandler) resetUserMFA(id string) error {
 	go h.jmsClient.ResetUserMFA(id)
 	return nil
 }
+
+var errLastAdmin error = &statusError{
+	status: http.StatusConflict, msg: "can not remove the last system administrator",
+}
+
+// uniqueIds 去除重复的 ID 并保持原有顺序
+func uniqueIds(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// missingIds 返回 requested 中不在 found 内的 ID
+func missingIds(requested, found []string) []string {
+	exists := make(map[string]bool, len(found))
+	for _, id := range found {
+		exists[id] = true
+	}
+	var missing []string
+	for _, id := range requested {
+		if !exists[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+type userGroupRelation struct {
+	UserID      string `gorm:"column:user_id"`
+	UserGroupID string `gorm:"column:user_group_id"`
+}
+
+func (userGroupRelation) TableName() string {
+	return "users_user_groups"
+}
+
+func isSystemAdmin(tx *gorm.DB, userID string) (bool, error) {
+	var count int64
+	err := tx.Model(&models.RbacRoleBinding{}).
+		Where("scope = ? AND role_id = ? AND user_id = ?", models.SystemRoleScope, models.SystemAdminRoleID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// checkAdminRemains 系统管理员被删除、禁用或移除角色后，至少还要保留一个有效的系统管理员
+func checkAdminRemains(tx *gorm.DB, wasAdmin bool) error {
+	if !wasAdmin {
+		return nil
+	}
+	admins := tx.Model(&models.RbacRoleBinding{}).Select("user_id").
+		Where("scope = ? AND role_id = ?", models.SystemRoleScope, models.SystemAdminRoleID)
+	var count int64
+	if err := tx.Model(&models.User{}).Where("id IN (?) AND is_active = ?", admins, true).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errLastAdmin
+	}
+	return nil
+}
+
+// canDeleteUser 删除前检查用户是否在当前组织中，以及删除后是否仍有有效的系统管理员
+func (h *ResourcesHandler) canDeleteUser(id string) (found bool, err error) {
+	current, err := h.findOrgUser(h.db, id)
+	if err != nil || current.ID == "" {
+		return false, err
+	}
+	wasAdmin, err := isSystemAdmin(h.db, id)
+	if err != nil || !wasAdmin {
+		return true, err
+	}
+	admins := h.db.Model(&models.RbacRoleBinding{}).Select("user_id").
+		Where("scope = ? AND role_id = ? AND user_id <> ?", models.SystemRoleScope, models.SystemAdminRoleID, id)
+	var count int64
+	if err = h.db.Model(&models.User{}).Where("id IN (?) AND is_active = ?", admins, true).
+		Count(&count).Error; err != nil {
+		return true, err
+	}
+	if count == 0 {
+		return true, errLastAdmin
+	}
+	return true, nil
+}
+
+func (h *ResourcesHandler) findOrgUser(tx *gorm.DB, id string) (user models.User, err error) {
+	err = h.orgUsers(tx.Model(&models.User{}), "users").Select("users.id").
+		Where("users.id = ?", id).Limit(1).Find(&user).Error
+	return user, err
+}
+
+// updateUser 只更新请求体中出现的字段，roles 与 groups 出现时替换用户在当前组织下的角色及用户组
+func (h *ResourcesHandler) updateUser(c *gin.Context, id string) (err error) {
+	body, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+	var keys map[string]json.RawMessage
+	if err = json.Unmarshal(body, &keys); err != nil {
+		return err
+	}
+	var user models.User
+	if err = json.Unmarshal(body, &user); err != nil {
+		return err
+	}
+
+	columns, err := h.updateColumns(&models.User{}, keys)
+	if err != nil {
+		return err
+	}
+	user.ID = id
+	user.UpdatedBy = operatorName(c)
+	columns = append(columns, "updated_by")
+
+	_, rolesChanged := keys["roles"]
+	var roles []models.RbacRole
+	if rolesChanged && len(user.RoleIds) > 0 {
+		roleIds := uniqueIds(user.RoleIds)
+		if err = h.db.Where("id IN ?", roleIds).Find(&roles).Error; err != nil {
+			return err
+		}
+		found := make([]string, 0, len(roles))
+		for _, role := range roles {
+			found = append(found, role.ID)
+		}
+		if missing := missingIds(roleIds, found); len(missing) > 0 {
+			return newStatusError(http.StatusBadRequest, "roles not found: %s", strings.Join(missing, ", "))
+		}
+	}
+	_, activeChanged := keys["is_active"]
+	_, groupsChanged := keys["groups"]
+	var groups []models.UserGroup
+	if groupsChanged && len(user.UserGroups) > 0 {
+		var groupIds []string
+		for _, group := range user.UserGroups {
+			groupIds = append(groupIds, group.ID)
+		}
+		groupIds = uniqueIds(groupIds)
+		if err = h.userGroupScope(h.db).Where("id IN ?", groupIds).Find(&groups).Error; err != nil {
+			return err
+		}
+		found := make([]string, 0, len(groups))
+		for _, group := range groups {
+			found = append(found, group.ID)
+		}
+		if missing := missingIds(groupIds, found); len(missing) > 0 {
+			return newStatusError(http.StatusBadRequest, "groups not found: %s", strings.Join(missing, ", "))
+		}
+	}
+	user.UserGroups = nil
+	user.RoleIds = nil
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		current, txErr := h.findOrgUser(tx, id)
+		if txErr != nil {
+			return txErr
+		}
+		if current.ID == "" {
+			return gorm.ErrRecordNotFound
+		}
+		wasAdmin, txErr := isSystemAdmin(tx, id)
+		if txErr != nil {
+			return txErr
+		}
+		if txErr = tx.Model(&models.User{}).Where("id = ?", id).
+			Select(columns).Updates(&user).Error; txErr != nil {
+			return txErr
+		}
+
+		if rolesChanged {
+			if txErr = tx.Where("user_id = ? AND (scope = ? OR (scope = ? AND org_id = ?))",
+				id, models.SystemRoleScope, models.OrgRoleScope, h.orgID).
+				Delete(&models.RbacRoleBinding{}).Error; txErr != nil {
+				return txErr
+			}
+			var bindings []models.RbacRoleBinding
+			for _, role := range roles {
+				bindings = append(bindings, models.RbacRoleBinding{
+					ID:    uuid.New().String(),
+					Scope: role.Scope, UserID: id, RoleID: role.ID,
+					CreatedBy: user.UpdatedBy, UpdatedBy: user.UpdatedBy,
+					OrgID: h.bindingOrgID(role.Scope),
+				})
+			}
+			if len(bindings) > 0 {
+				if txErr = tx.Create(&bindings).Error; txErr != nil {
+					return txErr
+				}
+			}
+		}
+
+		if groupsChanged {
+			orgGroups := tx.Model(&models.UserGroup{}).Select("id").Where("org_id = ?", h.orgID)
+			if txErr = tx.Where("user_id = ? AND user_group_id IN (?)", id, orgGroups).
+				Delete(&userGroupRelation{}).Error; txErr != nil {
+				return txErr
+			}
+			var relations []userGroupRelation
+			for _, group := range groups {
+				relations = append(relations, userGroupRelation{UserID: id, UserGroupID: group.ID})
+			}
+			if len(relations) > 0 {
+				if txErr = tx.Create(&relations).Error; txErr != nil {
+					return txErr
+				}
+			}
+		}
+		return checkAdminRemains(tx, wasAdmin && (rolesChanged || activeChanged))
+	})
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{}
+	for _, column := range columns {
+		if value, exists := keys[column]; exists {
+			payload[column] = value
+		}
+	}
+	for _, key := range []string{"password", "password_strategy"} {
+		if value, exists := keys[key]; exists {
+			payload[key] = value
+		}
+	}
+	if rolesChanged {
+		jmsUser := (&models.User{Roles: roles}).ToJMSUser()
+		payload["system_roles"] = jmsUser.SystemRoles
+		payload["org_roles"] = jmsUser.OrgRoles
+	}
+	if groupsChanged {
+		groupIds := make([]string, 0, len(groups))
+		for _, group := range groups {
+			groupIds = append(groupIds, group.ID)
+		}
+		payload["groups"] = groupIds
+	}
+	go h.jmsClient.UpdateUser(id, payload)
+	return nil
+}
+
+func (h *ResourcesHandler) setUserActive(id string, active bool) error {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		current, txErr := h.findOrgUser(tx, id)
+		if txErr != nil {
+			return txErr
+		}
+		if current.ID == "" {
+			return gorm.ErrRecordNotFound
+		}
+		wasAdmin, txErr := isSystemAdmin(tx, id)
+		if txErr != nil {
+			return txErr
+		}
+		if txErr = tx.Model(&models.User{}).Where("id = ?", id).
+			Update("is_active", active).Error; txErr != nil {
+			return txErr
+		}
+		return checkAdminRemains(tx, wasAdmin && !active)
+	})
+	if err != nil {
+		return err
+	}
+	go h.jmsClient.UpdateUser(id, map[string]interface{}{"is_active": active})
+	return nil
+}
+
+// deleteUser 同时清理用户的角色绑定、用户组及授权关系，用户不在当前组织中时不做处理
+func (h *ResourcesHandler) deleteUser(id, cacheKey string) (err error) {
+	var found bool
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		current, txErr := h.findOrgUser(tx, id)
+		if txErr != nil || current.ID == "" {
+			return txErr
+		}
+		found = true
+		wasAdmin, txErr := isSystemAdmin(tx, id)
+		if txErr != nil {
+			return txErr
+		}
+		if txErr = tx.Where("user_id = ?", id).Delete(&models.RbacRoleBinding{}).Error; txErr != nil {
+			return txErr
+		}
+		if txErr = tx.Where("user_id = ?", id).Delete(&userGroupRelation{}).Error; txErr != nil {
+			return txErr
+		}
+		if txErr = tx.Table("perms_assetpermission_users").Where("user_id = ?", id).
+			Delete(nil).Error; txErr != nil {
+			return txErr
+		}
+		if txErr = tx.Where("id = ?", id).Delete(&models.User{}).Error; txErr != nil {
+			return txErr
+		}
+		return checkAdminRemains(tx, wasAdmin)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errResourceNotFound
+	}
+	// 本地删除的是用户本身，需要在全局组织下删除，否则只会将用户移出组织
+	go h.jmsClient.WithOrg(models.RootOrgID).DeleteUser(id, cacheKey)
+	return nil
+}
+
+func (h *ResourcesHandler) userGroupRelation(c *gin.Context) (err error) {
+	var req struct {
+		Action  string   `json:"action" binding:"required"`
+		GroupID string   `json:"group_id"`
+		UserIds []string `json:"user_ids"`
+	}
+	if err = c.ShouldBindJSON(&req); err != nil {
+		return err
+	}
+	if req.Action != "add" && req.Action != "remove" {
+		return fmt.Errorf("invalid action: %s", req.Action)
+	}
+	if len(req.UserIds) == 0 {
+		return fmt.Errorf("param user_ids is required")
+	}
+
+	var groupCount int64
+	err = h.userGroupScope(h.db.Model(models.UserGroup{})).Where("id = ?", req.GroupID).
+		Count(&groupCount).Error
+	if err != nil {
+		return err
+	}
+	if groupCount != 1 {
+		return fmt.Errorf("user group does not exist")
+	}
+
+	userIds := uniqueIds(req.UserIds)
+	if req.Action == "add" {
+		if err = h.checkOrgUsers(userIds); err != nil {
+			return err
+		}
+		var existingIds []string
+		if err = h.db.Model(&userGroupRelation{}).
+			Where("user_group_id = ? AND user_id IN ?", req.GroupID, userIds).
+			Pluck("user_id", &existingIds).Error; err != nil {
+			return err
+		}
+		existingMap := make(map[string]bool)
+		for _, id := range existingIds {
+			existingMap[id] = true
+		}
+
+		var newRelations []userGroupRelation
+		var newUserIds []string
+		for _, userID := range userIds {
+			if !existingMap[userID] {
+				existingMap[userID] = true
+				newRelations = append(newRelations, userGroupRelation{UserID: userID, UserGroupID: req.GroupID})
+				newUserIds = append(newUserIds, userID)
+			}
+		}
+		if len(newRelations) > 0 {
+			if err = h.db.CreateInBatches(&newRelations, 100).Error; err != nil {
+				return err
+			}
+			go h.jmsClient.UserGroupRelation("add", req.GroupID, newUserIds)
+		}
+		return nil
+	}
+
+	// 移除时不检查用户是否仍在当前组织中，已离开组织的用户也可以移出用户组
+	err = h.db.Where("user_group_id = ? AND user_id IN ?", req.GroupID, userIds).
+		Delete(&userGroupRelation{}).Error
+	if err != nil {
+		return err
+	}
+	go h.jmsClient.UserGroupRelation("remove", req.GroupID, userIds)
+	return nil
+}
+
+// checkOrgUsers 只能把当前组织中的用户加入用户组，userIds 需要已去重
+func (h *ResourcesHandler) checkOrgUsers(userIds []string) error {
+	var userCount int64
+	err := h.orgUsers(h.db.Model(models.User{}), "users").Where("users.id IN ?", userIds).
+		Count(&userCount).Error
+	if err != nil {
+		return err
+	}
+	if userCount != int64(len(userIds)) {
+		return fmt.Errorf("there are illegal ID in param user_ids")
+	}
+	return nil
+}
+
+// updateUserGroup 只更新请求体中出现的字段，成员通过 user_group_members 维护
+func (h *ResourcesHandler) updateUserGroup(c *gin.Context, id string) (err error) {
+	body, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+	var keys map[string]json.RawMessage
+	if err = json.Unmarshal(body, &keys); err != nil {
+		return err
+	}
+	var group models.UserGroup
+	if err = json.Unmarshal(body, &group); err != nil {
+		return err
+	}
+	if _, exists := keys["name"]; exists && group.Name == "" {
+		return fmt.Errorf("user group name is required")
+	}
+
+	columns, err := h.updateColumns(&models.UserGroup{}, keys)
+	if err != nil {
+		return err
+	}
+	group.ID = id
+	group.UpdatedBy = operatorName(c)
+	columns = append(columns, "updated_by")
+
+	result := h.userGroupScope(h.db.Model(&models.UserGroup{})).Where("id = ?", id).
+		Select(columns).Updates(&group)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	payload := map[string]interface{}{}
+	for _, column := range columns {
+		if value, exists := keys[column]; exists {
+			payload[column] = value
+		}
+	}
+	go h.jmsClient.UpdateUserGroup(id, payload)
+	return nil
+}
+
+// deleteUserGroup 同时清理用户组的成员及授权关系
+func (h *ResourcesHandler) deleteUserGroup(id, cacheKey string) (err error) {
+	var found bool
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if txErr := h.userGroupScope(tx.Model(&models.UserGroup{})).Where("id = ?", id).
+			Count(&count).Error; txErr != nil || count == 0 {
+			return txErr
+		}
+		found = true
+		if txErr := tx.Where("user_group_id = ?", id).Delete(&userGroupRelation{}).Error; txErr != nil {
+			return txErr
+		}
+		if txErr := tx.Table("perms_assetpermission_user_groups").Where("usergroup_id = ?", id).
+			Delete(nil).Error; txErr != nil {
+			return txErr
+		}
+		return tx.Where("id = ?", id).Delete(&models.UserGroup{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errResourceNotFound
+	}
+	go h.jmsClient.DeleteUserGroup(id, cacheKey)
+	return nil
+}
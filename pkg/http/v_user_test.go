@@ -0,0 +1,181 @@
+package pkg
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"middleman/pkg/database/models"
+)
+
+func TestUniqueIds(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  []string
+		want []string
+	}{
+		{name: "nil", ids: nil, want: []string{}},
+		{name: "unique", ids: []string{"a", "b"}, want: []string{"a", "b"}},
+		{name: "keeps order", ids: []string{"b", "a", "b", "c", "a"}, want: []string{"b", "a", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := uniqueIds(tt.ids); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("uniqueIds(%q) = %q, want %q", tt.ids, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingIds(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested []string
+		found     []string
+		want      []string
+	}{
+		{name: "all found", requested: []string{"a", "b"}, found: []string{"b", "a"}, want: nil},
+		{name: "none requested", requested: nil, found: []string{"a"}, want: nil},
+		{name: "some missing", requested: []string{"a", "b", "c"}, found: []string{"b"}, want: []string{"a", "c"}},
+		{name: "none found", requested: []string{"a"}, found: nil, want: []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := missingIds(tt.requested, tt.found); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("missingIds(%q, %q) = %q, want %q", tt.requested, tt.found, got, tt.want)
+			}
+		})
+	}
+}
+
+// newCountDB 返回 DryRun 的 DB，记录 Count 生成的 SQL，并以 count 作为结果。
+// 子查询生成 SQL 时同样会经过查询回调，需要排除
+func newCountDB(t *testing.T, count int64, queries *[]string) *gorm.DB {
+	t.Helper()
+	db := newDryRunDB(t)
+	err := db.Callback().Query().After("gorm:query").Register("test:count", func(tx *gorm.DB) {
+		if sql := tx.Statement.SQL.String(); strings.HasPrefix(sql, "SELECT count(*)") {
+			*queries = append(*queries, sql)
+			tx.RowsAffected = count
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestCheckAdminRemains(t *testing.T) {
+	tests := []struct {
+		name        string
+		wasAdmin    bool
+		admins      int64
+		wantQueries int
+		wantLast    bool
+	}{
+		{name: "not an admin", wasAdmin: false, admins: 0, wantQueries: 0},
+		{name: "other admins remain", wasAdmin: true, admins: 2, wantQueries: 1},
+		{name: "last admin", wasAdmin: true, admins: 0, wantQueries: 1, wantLast: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queries []string
+			db := newCountDB(t, tt.admins, &queries)
+
+			err := checkAdminRemains(db, tt.wasAdmin)
+			if (err == errLastAdmin) != tt.wantLast || (err != nil && err != errLastAdmin) {
+				t.Fatalf("checkAdminRemains() error = %v, wantLast %v", err, tt.wantLast)
+			}
+			if tt.wantLast {
+				if status, ok := statusOf(err); !ok || status != http.StatusConflict {
+					t.Errorf("statusOf(errLastAdmin) = %d, %v, want %d", status, ok, http.StatusConflict)
+				}
+			}
+			if len(queries) != tt.wantQueries {
+				t.Fatalf("checkAdminRemains() ran %d count queries, want %d", len(queries), tt.wantQueries)
+			}
+			// 只统计仍然有效的系统管理员
+			for _, sql := range queries {
+				for _, part := range []string{"rbac_role_bindings", "role_id = $2", "is_active = $3"} {
+					if !strings.Contains(sql, part) {
+						t.Errorf("query %s, want to contain %s", sql, part)
+					}
+				}
+			}
+		})
+	}
+}
+
+// newDeleteUserDB 查询用户时按 found 返回，Count 查询依次返回 counts 中的值
+func newDeleteUserDB(t *testing.T, found bool, counts []int64) *gorm.DB {
+	t.Helper()
+	db := newDryRunDB(t)
+	err := db.Callback().Query().After("gorm:query").Register("test:delete_user", func(tx *gorm.DB) {
+		if user, ok := tx.Statement.Dest.(*models.User); ok && found {
+			user.ID = "1"
+			tx.RowsAffected = 1
+			return
+		}
+		count, ok := tx.Statement.Dest.(*int64)
+		if ok && strings.HasPrefix(tx.Statement.SQL.String(), "SELECT count(*)") && len(counts) > 0 {
+			*count, tx.RowsAffected, counts = counts[0], counts[0], counts[1:]
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestCanDeleteUser(t *testing.T) {
+	tests := []struct {
+		name      string
+		found     bool
+		counts    []int64
+		wantFound bool
+		wantErr   error
+	}{
+		{name: "not in org"},
+		{name: "not an admin", found: true, counts: []int64{0}, wantFound: true},
+		{name: "other admins remain", found: true, counts: []int64{1, 2}, wantFound: true},
+		{name: "last admin", found: true, counts: []int64{1, 0}, wantFound: true, wantErr: errLastAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ResourcesHandler{db: newDeleteUserDB(t, tt.found, tt.counts), orgID: models.DefaultOrgID}
+			found, err := h.canDeleteUser("1")
+			if found != tt.wantFound || err != tt.wantErr {
+				t.Errorf("canDeleteUser() = %v, %v, want %v, %v", found, err, tt.wantFound, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckOrgUsers(t *testing.T) {
+	tests := []struct {
+		name    string
+		ids     []string
+		count   int64
+		wantErr bool
+	}{
+		{name: "all in org", ids: []string{"a", "b"}, count: 2},
+		{name: "repeated ids", ids: []string{"a", "b", "a"}, count: 2},
+		{name: "some not in org", ids: []string{"a", "b", "c"}, count: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queries []string
+			h := &ResourcesHandler{db: newCountDB(t, tt.count, &queries), orgID: testOrgID}
+			err := h.checkOrgUsers(uniqueIds(tt.ids))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkOrgUsers(%q) error = %v, wantErr %v", tt.ids, err, tt.wantErr)
+			}
+			if len(queries) != 1 || !strings.Contains(queries[0], "rbac_role_bindings") {
+				t.Errorf("checkOrgUsers() queries = %q, want one count scoped to the org", queries)
+			}
+		})
+	}
+}
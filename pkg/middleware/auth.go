@@ -1,14 +1,33 @@
 package middleware
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
 	"middleman/pkg/consts"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"middleman/pkg/config"
 	"middleman/pkg/database"
 	mm "middleman/pkg/middleware/models"
+	"middleman/pkg/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	TimestampHeader = "X-MM-Timestamp"
+	NonceHeader     = "X-MM-Nonce"
+
+	// SecretRevealPathSuffix 查看账号密文的路由
+	SecretRevealPathSuffix = "/:id/secret/"
 )
 
 func AccessKeyMiddleware() gin.HandlerFunc {
@@ -23,14 +42,22 @@ func AccessKeyMiddleware() gin.HandlerFunc {
 		}
 
 		// 格式应为: "Bearer {AccessKeyID}:{SecretAccessKey}"
+		// 或: "MM-HMAC-SHA256 {AccessKeyID}:{Signature}"
 		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
+		if len(parts) != 2 || (parts[0] != "Bearer" && parts[0] != utils.SignatureAlgorithm) {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid Authorization format",
 				"code":  40102,
 			})
 			return
 		}
+		if parts[0] == "Bearer" && !config.GetConf().AuthAllowBearer {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Bearer authorization is disabled, please sign the request",
+				"code":  40106,
+			})
+			return
+		}
 
 		credentials := strings.Split(parts[1], ":")
 		if len(credentials) != 2 {
@@ -41,12 +68,8 @@ func AccessKeyMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		var server mm.JumpServer
 		db := database.GetDBManager().GetDefaultDB()
-		err := db.Model(&mm.JumpServer{}).
-			Where("access_key = ? AND secret_key = ?", credentials[0], credentials[1]).
-			Find(&server).Error
-
+		cred, err := lookupCredential(db, credentials[0])
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid access key or secret key",
@@ -54,14 +77,154 @@ func AccessKeyMiddleware() gin.HandlerFunc {
 			})
 			return
 		}
-		if server.Name == "" {
+
+		var usedKey string
+		if parts[0] == "Bearer" {
+			usedKey = matchKey(cred, credentials[1])
+		} else {
+			usedKey, err = verifySignature(c, cred, credentials[0], credentials[1])
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+					"error": err.Error(),
+					"code":  41301,
+				})
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"error": err.Error(),
+					"code":  40107,
+				})
+				return
+			}
+		}
+		if cred.server.Name == "" || usedKey == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid access key or secret key",
 				"code":  40105,
 			})
 			return
 		}
-		c.Set(consts.AuthDBInfoContextKey, server)
+		if parts[0] == "Bearer" {
+			utils.GetLogger().Warn("Bearer authorization used by %s, please sign the request", cred.server.Name)
+		}
+
+		if apiKey := cred.apiKey; apiKey != nil {
+			revealSecret := strings.HasSuffix(c.FullPath(), SecretRevealPathSuffix)
+			if !apiKey.Allows(c.Request.Method, c.Query("m_type"), revealSecret) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error": fmt.Sprintf("API key %s is not allowed to access this resource", apiKey.Name),
+					"code":  40301,
+				})
+				return
+			}
+			now := time.Now()
+			database.GetDBManager().GetDefaultDB().Model(apiKey).
+				UpdateColumn("last_used_at", now)
+			c.Set(consts.APIKeyContextKey, *apiKey)
+		}
+		c.Header("Middleman-Key-Used", usedKey)
+		c.Set(consts.AuthKeyContextKey, usedKey)
+		c.Set(consts.AuthAccessKeyContextKey, credentials[0])
+		c.Set(consts.AuthDBInfoContextKey, cred.server)
 		c.Next()
 	}
 }
+
+type credential struct {
+	server  mm.JumpServer
+	apiKey  *mm.APIKey
+	secret  string
+	usedKey string
+}
+
+// lookupCredential 依次查找节点的当前密钥、宽限期内的旧密钥以及附加的 API key
+func lookupCredential(db *gorm.DB, accessKey string) (*credential, error) {
+	var cred credential
+	err := db.Model(&mm.JumpServer{}).
+		Where("access_key = ? OR prev_access_key = ?", accessKey, accessKey).
+		Find(&cred.server).Error
+	if err != nil {
+		return nil, err
+	}
+	if cred.server.Name != "" {
+		if cred.server.AccessKey == accessKey {
+			cred.secret, cred.usedKey = cred.server.SecretKey, mm.KeyCurrent
+		} else if cred.server.PrevKeyValid() && cred.server.PrevAccessKey == accessKey {
+			cred.secret, cred.usedKey = cred.server.PrevSecretKey, mm.KeyPrevious
+		}
+		return &cred, nil
+	}
+
+	var apiKey mm.APIKey
+	err = db.Model(&mm.APIKey{}).Preload("JumpServer").
+		Where("access_key = ?", accessKey).Find(&apiKey).Error
+	if err != nil {
+		return nil, err
+	}
+	if apiKey.ID != 0 && !apiKey.IsExpired() {
+		cred.server = apiKey.JumpServer
+		cred.apiKey = &apiKey
+		cred.secret, cred.usedKey = apiKey.SecretKey, mm.KeyAPIKey
+	}
+	return &cred, nil
+}
+
+func matchKey(cred *credential, secretKey string) string {
+	if cred.usedKey == "" || subtle.ConstantTimeCompare([]byte(cred.secret), []byte(secretKey)) != 1 {
+		return ""
+	}
+	return cred.usedKey
+}
+
+func verifySignature(c *gin.Context, cred *credential, accessKey, signature string) (string, error) {
+	if cred.usedKey == "" {
+		return "", nil
+	}
+
+	timestamp := c.GetHeader(TimestampHeader)
+	nonce := c.GetHeader(NonceHeader)
+	if timestamp == "" || nonce == "" {
+		return "", fmt.Errorf("missing %s or %s header", TimestampHeader, NonceHeader)
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s header", TimestampHeader)
+	}
+	ttl := int64(config.GetConf().AuthSignatureTTL)
+	if delta := time.Now().Unix() - ts; delta > ttl || delta < -ttl {
+		return "", fmt.Errorf("request signature expired")
+	}
+
+	var body []byte
+	if c.Request.Body != nil {
+		// 认证通过前读取请求体，需要限制大小
+		limit := int64(config.GetConf().AuthMaxBodySize)
+		if body, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit)); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return "", fmt.Errorf("request body exceeds %d bytes: %w", limit, err)
+			}
+			return "", fmt.Errorf("read request body failed")
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	expected := utils.SignRequest(
+		cred.secret, c.Request.Method, c.Request.URL.RequestURI(), body, timestamp, nonce,
+	)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return "", nil
+	}
+
+	// 时间戳窗口内同一个 nonce 只允许使用一次
+	nonceKey := fmt.Sprintf("nonce-%s-%s", accessKey, nonce)
+	stored, err := utils.GetCache().SetIfAbsent(nonceKey, "", 2*ttl)
+	if err != nil {
+		return "", fmt.Errorf("check nonce failed")
+	}
+	if !stored {
+		return "", fmt.Errorf("nonce has already been used")
+	}
+	return cred.usedKey, nil
+}
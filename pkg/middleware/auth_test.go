@@ -0,0 +1,299 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"middleman/pkg/config"
+	mm "middleman/pkg/middleware/models"
+	"middleman/pkg/utils"
+)
+
+// nonce 缓存写入临时目录
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "middleman-auth")
+	if err != nil {
+		panic(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	gin.SetMode(gin.TestMode)
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+const (
+	testAccessKey     = "test-access-key"
+	testSecret        = "test-secret-key"
+	testPrevAccessKey = "test-prev-access-key"
+	testPrevSecret    = "test-prev-secret-key"
+	testURI           = "/middleman/resources/?m_type=user"
+)
+
+// newFakeDB 返回 DryRun 的 DB，查询时按表名返回 rows 中预设的记录
+func newFakeDB(t *testing.T, rows map[string]interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun: true, DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Callback().Query().After("gorm:after_query").Register("test:fake", func(tx *gorm.DB) {
+		row, exists := rows[tx.Statement.Table]
+		if !exists {
+			return
+		}
+		dest := reflect.ValueOf(tx.Statement.Dest).Elem()
+		value := reflect.ValueOf(row)
+		switch dest.Kind() {
+		case reflect.Slice:
+			dest.Set(reflect.Append(dest, value))
+		case reflect.Int64:
+			dest.SetInt(value.Int())
+		default:
+			dest.Set(value)
+		}
+		tx.RowsAffected = 1
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newTestServer(prevExpiredAt time.Time) mm.JumpServer {
+	return mm.JumpServer{
+		BaseJumpServer:   mm.BaseJumpServer{Name: "slave-1", Role: mm.RoleSlave},
+		AccessKey:        testAccessKey,
+		SecretKey:        testSecret,
+		PrevAccessKey:    testPrevAccessKey,
+		PrevSecretKey:    testPrevSecret,
+		PrevKeyExpiredAt: &prevExpiredAt,
+	}
+}
+
+func newSignedContext(timestamp, nonce string, body []byte) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, testURI, bytes.NewReader(body))
+	if timestamp != "" {
+		c.Request.Header.Set(TimestampHeader, timestamp)
+	}
+	if nonce != "" {
+		c.Request.Header.Set(NonceHeader, nonce)
+	}
+	return c
+}
+
+func TestLookupCredential(t *testing.T) {
+	later, earlier := time.Now().Add(time.Hour), time.Now().Add(-time.Hour)
+	apiKey := mm.APIKey{
+		ID: 1, Name: "readonly", AccessKey: "api-access-key", SecretKey: "api-secret",
+		JumpServer: newTestServer(later),
+	}
+	expiredKey := apiKey
+	expiredKey.ExpiredAt = &earlier
+
+	tests := []struct {
+		name       string
+		rows       map[string]interface{}
+		accessKey  string
+		wantKey    string
+		wantSecret string
+		wantAPIKey bool
+	}{
+		{name: "current key", rows: map[string]interface{}{"jump_servers": newTestServer(later)},
+			accessKey: testAccessKey, wantKey: mm.KeyCurrent, wantSecret: testSecret},
+		{name: "previous key", rows: map[string]interface{}{"jump_servers": newTestServer(later)},
+			accessKey: testPrevAccessKey, wantKey: mm.KeyPrevious, wantSecret: testPrevSecret},
+		{name: "previous key expired", rows: map[string]interface{}{"jump_servers": newTestServer(earlier)},
+			accessKey: testPrevAccessKey},
+		{name: "api key", rows: map[string]interface{}{"api_keys": apiKey},
+			accessKey: "api-access-key", wantKey: mm.KeyAPIKey, wantSecret: "api-secret", wantAPIKey: true},
+		{name: "api key expired", rows: map[string]interface{}{"api_keys": expiredKey},
+			accessKey: "api-access-key"},
+		{name: "unknown access key", rows: map[string]interface{}{}, accessKey: "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cred, err := lookupCredential(newFakeDB(t, tt.rows), tt.accessKey)
+			if err != nil {
+				t.Fatalf("lookupCredential() error = %v", err)
+			}
+			if cred.usedKey != tt.wantKey || cred.secret != tt.wantSecret {
+				t.Errorf("lookupCredential() = %q/%q, want %q/%q", cred.usedKey, cred.secret, tt.wantKey, tt.wantSecret)
+			}
+			if (cred.apiKey != nil) != tt.wantAPIKey {
+				t.Errorf("lookupCredential() apiKey = %v, want %v", cred.apiKey, tt.wantAPIKey)
+			}
+			// API key 以所属节点的身份访问
+			if tt.wantAPIKey && cred.server.Name != apiKey.JumpServer.Name {
+				t.Errorf("lookupCredential() server = %q, want %q", cred.server.Name, apiKey.JumpServer.Name)
+			}
+		})
+	}
+}
+
+func TestMatchKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		cred   *credential
+		secret string
+		want   string
+	}{
+		{name: "current key", cred: &credential{secret: testSecret, usedKey: mm.KeyCurrent},
+			secret: testSecret, want: mm.KeyCurrent},
+		{name: "api key", cred: &credential{secret: testSecret, usedKey: mm.KeyAPIKey},
+			secret: testSecret, want: mm.KeyAPIKey},
+		{name: "wrong secret", cred: &credential{secret: testSecret, usedKey: mm.KeyCurrent},
+			secret: testPrevSecret},
+		{name: "no matching key", cred: &credential{}, secret: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchKey(tt.cred, tt.secret); got != tt.want {
+				t.Errorf("matchKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	ttl := int64(config.GetConf().AuthSignatureTTL)
+	now := time.Now().Unix()
+	body := []byte(`[{"name":"demo"}]`)
+
+	tests := []struct {
+		name      string
+		usedKey   string
+		timestamp string
+		noNonce   bool
+		secret    string
+		wantKey   string
+		wantErr   bool
+	}{
+		{name: "valid", usedKey: mm.KeyCurrent, timestamp: strconv.FormatInt(now, 10),
+			secret: testSecret, wantKey: mm.KeyCurrent},
+		{name: "previous key", usedKey: mm.KeyPrevious, timestamp: strconv.FormatInt(now, 10),
+			secret: testSecret, wantKey: mm.KeyPrevious},
+		{name: "within ttl", usedKey: mm.KeyCurrent, timestamp: strconv.FormatInt(now-ttl+5, 10),
+			secret: testSecret, wantKey: mm.KeyCurrent},
+		{name: "expired", usedKey: mm.KeyCurrent, timestamp: strconv.FormatInt(now-ttl-5, 10),
+			secret: testSecret, wantErr: true},
+		{name: "from the future", usedKey: mm.KeyCurrent, timestamp: strconv.FormatInt(now+ttl+5, 10),
+			secret: testSecret, wantErr: true},
+		{name: "invalid timestamp", usedKey: mm.KeyCurrent, timestamp: "yesterday",
+			secret: testSecret, wantErr: true},
+		{name: "missing timestamp", usedKey: mm.KeyCurrent, secret: testSecret, wantErr: true},
+		{name: "missing nonce", usedKey: mm.KeyCurrent, timestamp: strconv.FormatInt(now, 10),
+			noNonce: true, secret: testSecret, wantErr: true},
+		{name: "wrong secret", usedKey: mm.KeyCurrent, timestamp: strconv.FormatInt(now, 10),
+			secret: "other-secret"},
+		{name: "unknown access key", timestamp: strconv.FormatInt(now, 10), secret: testSecret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nonce := uuid.New().String()
+			if tt.noNonce {
+				nonce = ""
+			}
+			signature := utils.SignRequest(tt.secret, http.MethodPost, testURI, body, tt.timestamp, nonce)
+			c := newSignedContext(tt.timestamp, nonce, body)
+			cred := &credential{secret: testSecret, usedKey: tt.usedKey}
+
+			usedKey, err := verifySignature(c, cred, testAccessKey, signature)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifySignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if usedKey != tt.wantKey {
+				t.Errorf("verifySignature() usedKey = %q, want %q", usedKey, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureNonceReplay(t *testing.T) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := uuid.New().String()
+	body := []byte(`{}`)
+	signature := utils.SignRequest(testSecret, http.MethodPost, testURI, body, timestamp, nonce)
+	cred := &credential{secret: testSecret, usedKey: mm.KeyCurrent}
+
+	c := newSignedContext(timestamp, nonce, body)
+	if _, err := verifySignature(c, cred, testAccessKey, signature); err != nil {
+		t.Fatalf("first request: unexpected error %v", err)
+	}
+	c = newSignedContext(timestamp, nonce, body)
+	if _, err := verifySignature(c, cred, testAccessKey, signature); err == nil {
+		t.Fatal("replayed request: expected nonce error")
+	}
+
+	// 同一个 nonce 在不同的 access key 下互不影响
+	c = newSignedContext(timestamp, nonce, body)
+	if _, err := verifySignature(c, cred, testPrevAccessKey, signature); err != nil {
+		t.Fatalf("other access key: unexpected error %v", err)
+	}
+}
+
+func TestVerifySignatureKeepsBody(t *testing.T) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := uuid.New().String()
+	body := []byte(`{"name":"demo"}`)
+	signature := utils.SignRequest(testSecret, http.MethodPost, testURI, body, timestamp, nonce)
+	c := newSignedContext(timestamp, nonce, body)
+
+	cred := &credential{secret: testSecret, usedKey: mm.KeyCurrent}
+	if _, err := verifySignature(c, cred, testAccessKey, signature); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	data, err := c.GetRawData()
+	if err != nil || !bytes.Equal(data, body) {
+		t.Errorf("request body = %q, %v, want %q", data, err, body)
+	}
+}
+
+func TestVerifySignatureBodyTooLarge(t *testing.T) {
+	conf := config.GetConf()
+	conf.AuthMaxBodySize = 16
+	prev := config.GlobalConfig
+	config.GlobalConfig = &conf
+	defer func() { config.GlobalConfig = prev }()
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	cred := &credential{secret: testSecret, usedKey: mm.KeyCurrent}
+	tests := []struct {
+		name    string
+		body    []byte
+		wantErr bool
+	}{
+		{name: "within limit", body: bytes.Repeat([]byte("a"), 16)},
+		{name: "too large", body: bytes.Repeat([]byte("a"), 17), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nonce := uuid.New().String()
+			signature := utils.SignRequest(testSecret, http.MethodPost, testURI, tt.body, timestamp, nonce)
+			c := newSignedContext(timestamp, nonce, tt.body)
+
+			_, err := verifySignature(c, cred, testAccessKey, signature)
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) != tt.wantErr {
+				t.Errorf("verifySignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -4,24 +4,173 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strings"
 
 	"middleman/pkg/consts"
 	"middleman/pkg/database"
 	mm "middleman/pkg/middleware/models"
 )
 
+// AllSlaves SLAVE-NAME 为 * 时表示全部分节点
+const AllSlaves = "*"
+
+// 支持同时操作多个分节点的请求，GET 为跨分节点的聚合查询
+var broadcastMethods = map[string]bool{
+	http.MethodGet:   true,
+	http.MethodPost:  true,
+	http.MethodPatch: true,
+}
+
+// selectSlaves 返回标签匹配选择器的全部分节点，selector 为 nil 时返回全部分节点
+func selectSlaves(selector mm.LabelSelector) ([]mm.JumpServer, error) {
+	var servers, matched []mm.JumpServer
+	defaultDB := database.GetDBManager().GetDefaultDB()
+	err := defaultDB.Where("role = ?", mm.RoleSlave).Order("name").Find(&servers).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range servers {
+		if selector == nil || selector.Matches(s.Labels) {
+			matched = append(matched, s)
+		}
+	}
+	return matched, nil
+}
+
+func selectSlavesByName(names []string) ([]mm.JumpServer, error) {
+	var servers []mm.JumpServer
+	defaultDB := database.GetDBManager().GetDefaultDB()
+	err := defaultDB.Where("name IN ? AND role = ?", names, mm.RoleSlave).
+		Order("name").Find(&servers).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(servers) != len(names) {
+		found := map[mm.NameType]bool{}
+		for _, s := range servers {
+			found[s.Name] = true
+		}
+		for _, name := range names {
+			if !found[mm.NameType(name)] {
+				return nil, fmt.Errorf("slave node %s not found", name)
+			}
+		}
+	}
+	return servers, nil
+}
+
+// resolveMasterTargets 主节点通过 SLAVE-NAME 或 SLAVE-SELECTOR 指定要操作的分节点，
+// SLAVE-NAME 可以是单个名称、逗号分隔的名称列表或 *，broadcast 表示使用了多节点的写法
+func resolveMasterTargets(c *gin.Context) (servers []mm.JumpServer, broadcast bool, ok bool) {
+	dbName := strings.TrimSpace(c.GetHeader("SLAVE-NAME"))
+	selectorText := c.GetHeader("SLAVE-SELECTOR")
+	if dbName != "" && selectorText != "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "SLAVE-NAME and SLAVE-SELECTOR can not be used together",
+			"code":  40003,
+		})
+		return nil, false, false
+	}
+
+	var err error
+	switch {
+	case selectorText != "":
+		var selector mm.LabelSelector
+		if selector, err = mm.ParseLabelSelector(selectorText); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+				"code":  40003,
+			})
+			return nil, false, false
+		}
+		broadcast = true
+		servers, err = selectSlaves(selector)
+	case dbName == AllSlaves:
+		broadcast = true
+		servers, err = selectSlaves(nil)
+	case strings.Contains(dbName, ","):
+		var names []string
+		for _, name := range strings.Split(dbName, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		broadcast = true
+		if servers, err = selectSlavesByName(names); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+				"code":  40002,
+			})
+			return nil, false, false
+		}
+	default:
+		var server mm.JumpServer
+		defaultDB := database.GetDBManager().GetDefaultDB()
+		err = defaultDB.Model(&mm.JumpServer{}).
+			Where("name = ? AND role = ?", dbName, mm.RoleSlave).Find(&server).Error
+		servers = []mm.JumpServer{server}
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to select slave nodes: %v", err),
+		})
+		return nil, false, false
+	}
+	if broadcast && len(servers) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "No slave node matched",
+			"code":  40004,
+		})
+		return nil, false, false
+	}
+	if len(servers) > 1 && !broadcastMethods[c.Request.Method] {
+		var names []mm.NameType
+		for _, s := range servers {
+			names = append(names, s.Name)
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   fmt.Sprintf("%s request must target exactly one slave node, got %d", c.Request.Method, len(servers)),
+			"details": names,
+			"code":    40004,
+		})
+		return nil, false, false
+	}
+	return servers, broadcast, true
+}
+
 func DatabaseMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var server, authServer mm.JumpServer
-		authServer = c.MustGet(consts.AuthDBInfoContextKey).(mm.JumpServer)
+		var servers []mm.JumpServer
+		var broadcast bool
+		authServer := c.MustGet(consts.AuthDBInfoContextKey).(mm.JumpServer)
 		if authServer.Role == mm.RoleMaster {
-			dbName := c.GetHeader("SLAVE-NAME")
-			defaultDB := database.GetDBManager().GetDefaultDB()
-			defaultDB.Model(&mm.JumpServer{}).
-				Where("name = ? AND role = ?", dbName, mm.RoleSlave).Find(&server)
+			var ok bool
+			if servers, broadcast, ok = resolveMasterTargets(c); !ok {
+				return
+			}
 		} else {
-			server = authServer
+			dbName := c.GetHeader("SLAVE-NAME")
+			if (dbName != "" && dbName != string(authServer.Name)) || c.GetHeader("SLAVE-SELECTOR") != "" {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error": "Slave node can only access its own database",
+					"code":  40303,
+				})
+				return
+			}
+			servers = []mm.JumpServer{authServer}
 		}
+
+		if broadcast {
+			// 单个分节点的数据库异常由处理函数在结果中单独报告
+			c.Set(consts.DBTargetsContextKey, servers)
+			if len(servers) == 1 {
+				c.Set(consts.DBInfoContextKey, servers[0])
+			}
+			c.Next()
+			return
+		}
+
+		server := servers[0]
 		if server.Name == "" {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 				"error": fmt.Sprintf("Invalid branch node name"),
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal([]string(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (l *StringList) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return fmt.Errorf("不支持的类型: %T", value)
+	}
+	return json.Unmarshal(bytes, l)
+}
+
+func (l StringList) Contains(value string) bool {
+	for _, v := range l {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey 节点的附加密钥，可以限制请求方法及资源类型，RevealSecret 为 true 时才能查看账号密文
+type APIKey struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	JumpServerID uint       `json:"-" gorm:"not null;uniqueIndex:idx_jms_key_name"`
+	Name         string     `json:"name" gorm:"not null;size:128;uniqueIndex:idx_jms_key_name"`
+	AccessKey    string     `json:"access_key" gorm:"type:varchar(36);not null;uniqueIndex"`
+	SecretKey    string     `json:"-" gorm:"not null"`
+	Methods      StringList `json:"methods" gorm:"type:jsonb;not null"`
+	MTypes       StringList `json:"m_types" gorm:"type:jsonb;not null"`
+	RevealSecret bool       `json:"reveal_secret" gorm:"not null;default:false"`
+	ExpiredAt    *time.Time `json:"expired_at" gorm:"default:null"`
+	LastUsedAt   *time.Time `json:"last_used_at" gorm:"default:null"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"autoCreateTime"`
+
+	JumpServer JumpServer `json:"-" gorm:"foreignKey:JumpServerID;constraint:OnDelete:CASCADE"`
+}
+
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiredAt != nil && k.ExpiredAt.Before(time.Now())
+}
+
+// Allows 方法或资源类型列表为空时表示不限制，查看账号密文需要单独授权
+func (k *APIKey) Allows(method, mType string, revealSecret bool) bool {
+	if revealSecret && !k.RevealSecret {
+		return false
+	}
+	if len(k.Methods) > 0 && !k.Methods.Contains(method) {
+		return false
+	}
+	if len(k.MTypes) > 0 && !k.MTypes.Contains(mType) {
+		return false
+	}
+	return true
+}
+
+func (k *APIKey) BeforeSave(tx *gorm.DB) error {
+	ciphertext, err := encryptText(k.SecretKey)
+	if err != nil {
+		return fmt.Errorf("加密 Secret key 失败: %w", err)
+	}
+	k.SecretKey = ciphertext
+	return nil
+}
+
+func (k *APIKey) AfterFind(tx *gorm.DB) error {
+	plaintext, err := decryptText(k.SecretKey)
+	if err != nil {
+		return err
+	}
+	k.SecretKey = plaintext
+	return nil
+}
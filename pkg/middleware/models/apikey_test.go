@@ -0,0 +1,105 @@
+package models
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyAllows(t *testing.T) {
+	tests := []struct {
+		name         string
+		methods      StringList
+		mTypes       StringList
+		revealSecret bool
+		method       string
+		mType        string
+		reveal       bool
+		want         bool
+	}{
+		{name: "unrestricted", method: http.MethodDelete, mType: "user", want: true},
+		{name: "allowed method", methods: StringList{"GET", "POST"}, method: http.MethodPost, want: true},
+		{name: "method is case insensitive", methods: StringList{"get"}, method: http.MethodGet, want: true},
+		{name: "method not allowed", methods: StringList{"GET"}, method: http.MethodDelete},
+		{name: "allowed m_type", mTypes: StringList{"user", "asset"}, method: http.MethodGet, mType: "asset",
+			want: true},
+		{name: "m_type not allowed", mTypes: StringList{"user"}, method: http.MethodGet, mType: "perm"},
+		{name: "missing m_type", mTypes: StringList{"user"}, method: http.MethodGet},
+		{name: "both allowed", methods: StringList{"GET"}, mTypes: StringList{"user"},
+			method: http.MethodGet, mType: "user", want: true},
+		{name: "m_type allowed but method not", methods: StringList{"GET"}, mTypes: StringList{"user"},
+			method: http.MethodPost, mType: "user"},
+		{name: "reveal not granted", method: http.MethodGet, mType: "account", reveal: true},
+		{name: "reveal granted", revealSecret: true, method: http.MethodGet, mType: "account", reveal: true,
+			want: true},
+		{name: "reveal granted but m_type not", revealSecret: true, mTypes: StringList{"user"},
+			method: http.MethodGet, mType: "account", reveal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &APIKey{Methods: tt.methods, MTypes: tt.mTypes, RevealSecret: tt.revealSecret}
+			if got := key.Allows(tt.method, tt.mType, tt.reveal); got != tt.want {
+				t.Errorf("Allows(%s, %q, %v) = %v, want %v", tt.method, tt.mType, tt.reveal, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyIsExpired(t *testing.T) {
+	earlier, later := time.Now().Add(-time.Minute), time.Now().Add(time.Minute)
+	tests := []struct {
+		name      string
+		expiredAt *time.Time
+		want      bool
+	}{
+		{name: "never expires", expiredAt: nil},
+		{name: "expires later", expiredAt: &later},
+		{name: "expired", expiredAt: &earlier, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &APIKey{ExpiredAt: tt.expiredAt}
+			if got := key.IsExpired(); got != tt.want {
+				t.Errorf("IsExpired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringList(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    StringList
+		wantErr bool
+	}{
+		{name: "bytes", value: []byte(`["GET","POST"]`), want: StringList{"GET", "POST"}},
+		{name: "string", value: `["user"]`, want: StringList{"user"}},
+		{name: "null", value: nil, want: nil},
+		{name: "unsupported type", value: 1, wantErr: true},
+		{name: "invalid json", value: "GET", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got StringList
+			err := got.Scan(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Scan() = %q, want %q", got, tt.want)
+			}
+			value, err := got.Value()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var again StringList
+			if err = again.Scan(value); err != nil || len(again) != len(got) {
+				t.Errorf("Value() = %v, does not scan back: %v", value, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// SecretRevealLog 账号密文的查看记录
+type SecretRevealLog struct {
+	ID         uint     `json:"id" gorm:"primaryKey"`
+	SlaveName  NameType `json:"slave_name" gorm:"not null;size:128;index"`
+	AccountID  string   `json:"account_id" gorm:"type:varchar(36);not null;index"`
+	AssetID    string   `json:"asset_id" gorm:"type:varchar(36)"`
+	Username   string   `json:"username" gorm:"size:128"`
+	RevealedBy string   `json:"revealed_by" gorm:"size:128"`
+	// 认证使用的 access key 及 API key，用于区分同一节点下的不同调用方
+	AccessKey string    `json:"access_key" gorm:"type:varchar(36);index"`
+	APIKeyID  *uint     `json:"api_key_id" gorm:"default:null"`
+	RemoteIP  string    `json:"remote_ip" gorm:"size:64"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	EnrollmentActive  = "active"
+	EnrollmentUsed    = "used"
+	EnrollmentRevoked = "revoked"
+	EnrollmentExpired = "expired"
+)
+
+// EnrollmentToken 一次性注册令牌，只保存令牌的摘要
+type EnrollmentToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	TokenHash  string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	NodeName   NameType   `json:"node_name" gorm:"not null;size:128;index"`
+	Role       RoleType   `json:"role" gorm:"not null"`
+	ExpiredAt  time.Time  `json:"expired_at" gorm:"not null"`
+	CreatedBy  string     `json:"created_by" gorm:"size:128"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	RevokedAt  *time.Time `json:"revoked_at" gorm:"default:null"`
+	UsedAt     *time.Time `json:"used_at" gorm:"default:null"`
+	UsedBy     string     `json:"used_by" gorm:"size:128"`
+	UsedFromIP string     `json:"used_from_ip" gorm:"size:64"`
+
+	Status string `json:"status" gorm:"-"`
+}
+
+func HashEnrollmentToken(token string) string {
+	hashed := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hashed[:])
+}
+
+func (t *EnrollmentToken) GetStatus() string {
+	switch {
+	case t.RevokedAt != nil:
+		return EnrollmentRevoked
+	case t.UsedAt != nil:
+		return EnrollmentUsed
+	case t.ExpiredAt.Before(time.Now()):
+		return EnrollmentExpired
+	default:
+		return EnrollmentActive
+	}
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnrollmentTokenGetStatus(t *testing.T) {
+	now := time.Now()
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+	tests := []struct {
+		name  string
+		token EnrollmentToken
+		want  string
+	}{
+		{name: "active", token: EnrollmentToken{ExpiredAt: later}, want: EnrollmentActive},
+		{name: "expired", token: EnrollmentToken{ExpiredAt: earlier}, want: EnrollmentExpired},
+		{name: "used", token: EnrollmentToken{ExpiredAt: later, UsedAt: &now}, want: EnrollmentUsed},
+		{name: "revoked", token: EnrollmentToken{ExpiredAt: later, RevokedAt: &now}, want: EnrollmentRevoked},
+		{name: "revoked after use", token: EnrollmentToken{ExpiredAt: earlier, UsedAt: &now, RevokedAt: &now},
+			want: EnrollmentRevoked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.GetStatus(); got != tt.want {
+				t.Errorf("GetStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashEnrollmentToken(t *testing.T) {
+	hashed := HashEnrollmentToken("token")
+	if len(hashed) != 64 || hashed != HashEnrollmentToken("token") {
+		t.Errorf("HashEnrollmentToken() = %q, want a stable sha256 hex digest", hashed)
+	}
+	if hashed == HashEnrollmentToken("other") {
+		t.Error("HashEnrollmentToken() returns the same digest for different tokens")
+	}
+}
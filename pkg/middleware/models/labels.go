@@ -0,0 +1,120 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	labelKeyPattern   = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9_.\-/]{0,61}[a-zA-Z0-9])?$`)
+	labelValuePattern = regexp.MustCompile(`^[a-zA-Z0-9_.\-]{0,63}$`)
+)
+
+// Labels 节点的自定义标签，如 region=eu、env=prod
+type Labels map[string]string
+
+func (l Labels) Value() (driver.Value, error) {
+	if l == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(map[string]string(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (l *Labels) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return fmt.Errorf("不支持的类型: %T", value)
+	}
+	return json.Unmarshal(bytes, l)
+}
+
+func (l Labels) Validate() error {
+	for k, v := range l {
+		if !labelKeyPattern.MatchString(k) {
+			return fmt.Errorf("invalid label key: %q", k)
+		}
+		if !labelValuePattern.MatchString(v) {
+			return fmt.Errorf("invalid value of label %s: %q", k, v)
+		}
+	}
+	return nil
+}
+
+const (
+	SelectorEquals    = "="
+	SelectorNotEquals = "!="
+	SelectorExists    = "exists"
+)
+
+type LabelRequirement struct {
+	Key      string
+	Operator string
+	Value    string
+}
+
+func (r LabelRequirement) Matches(labels Labels) bool {
+	value, exists := labels[r.Key]
+	switch r.Operator {
+	case SelectorEquals:
+		return exists && value == r.Value
+	case SelectorNotEquals:
+		return !exists || value != r.Value
+	default:
+		return exists
+	}
+}
+
+// LabelSelector 多个条件之间为且的关系
+type LabelSelector []LabelRequirement
+
+// ParseLabelSelector 解析 "region=eu,env!=dev,gpu" 格式的标签选择器
+func ParseLabelSelector(text string) (LabelSelector, error) {
+	var selector LabelSelector
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var r LabelRequirement
+		if idx := strings.Index(item, "!="); idx >= 0 {
+			r = LabelRequirement{Key: item[:idx], Operator: SelectorNotEquals, Value: item[idx+2:]}
+		} else if idx = strings.Index(item, "="); idx >= 0 {
+			r = LabelRequirement{Key: item[:idx], Operator: SelectorEquals, Value: item[idx+1:]}
+		} else {
+			r = LabelRequirement{Key: item, Operator: SelectorExists}
+		}
+		r.Key, r.Value = strings.TrimSpace(r.Key), strings.TrimSpace(r.Value)
+		if !labelKeyPattern.MatchString(r.Key) || !labelValuePattern.MatchString(r.Value) {
+			return nil, fmt.Errorf("invalid label selector: %q", item)
+		}
+		selector = append(selector, r)
+	}
+	if len(selector) == 0 {
+		return nil, fmt.Errorf("label selector is empty")
+	}
+	return selector, nil
+}
+
+func (s LabelSelector) Matches(labels Labels) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    LabelSelector
+		wantErr bool
+	}{
+		{
+			name: "equals",
+			text: "region=eu",
+			want: LabelSelector{{Key: "region", Operator: SelectorEquals, Value: "eu"}},
+		},
+		{
+			name: "all operators",
+			text: "region=eu,env!=dev,gpu",
+			want: LabelSelector{
+				{Key: "region", Operator: SelectorEquals, Value: "eu"},
+				{Key: "env", Operator: SelectorNotEquals, Value: "dev"},
+				{Key: "gpu", Operator: SelectorExists},
+			},
+		},
+		{
+			name: "spaces and empty items",
+			text: " region = eu , ,gpu,",
+			want: LabelSelector{
+				{Key: "region", Operator: SelectorEquals, Value: "eu"},
+				{Key: "gpu", Operator: SelectorExists},
+			},
+		},
+		{
+			name: "empty value",
+			text: "env=",
+			want: LabelSelector{{Key: "env", Operator: SelectorEquals, Value: ""}},
+		},
+		{
+			name: "key with prefix",
+			text: "example.com/zone!=a-1",
+			want: LabelSelector{{Key: "example.com/zone", Operator: SelectorNotEquals, Value: "a-1"}},
+		},
+		{name: "empty", text: "", wantErr: true},
+		{name: "only commas", text: " , ,", wantErr: true},
+		{name: "missing key", text: "=eu", wantErr: true},
+		{name: "invalid key", text: "-region=eu", wantErr: true},
+		{name: "invalid value", text: "region=e u", wantErr: true},
+		{name: "value with operator", text: "region==eu", wantErr: true},
+		{name: "key too long", text: strings.Repeat("k", 64), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLabelSelector(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLabelSelector(%q) error = %v, wantErr %v", tt.text, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLabelSelector(%q) = %#v, want %#v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := Labels{"region": "eu", "env": "prod"}
+
+	tests := []struct {
+		selector string
+		labels   Labels
+		want     bool
+	}{
+		{selector: "region=eu", labels: labels, want: true},
+		{selector: "region=us", labels: labels, want: false},
+		{selector: "region=eu,env=prod", labels: labels, want: true},
+		{selector: "region=eu,env=dev", labels: labels, want: false},
+		{selector: "env!=dev", labels: labels, want: true},
+		{selector: "env!=prod", labels: labels, want: false},
+		{selector: "gpu!=true", labels: labels, want: true},
+		{selector: "region", labels: labels, want: true},
+		{selector: "gpu", labels: labels, want: false},
+		{selector: "region", labels: nil, want: false},
+		{selector: "region!=eu", labels: nil, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			selector, err := ParseLabelSelector(tt.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := selector.Matches(tt.labels); got != tt.want {
+				t.Errorf("%q.Matches(%v) = %v, want %v", tt.selector, tt.labels, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  Labels
+		wantErr bool
+	}{
+		{name: "valid", labels: Labels{"region": "eu", "example.com/zone": "a_1"}},
+		{name: "empty value", labels: Labels{"region": ""}},
+		{name: "nil", labels: nil},
+		{name: "invalid key", labels: Labels{"region!": "eu"}, wantErr: true},
+		{name: "empty key", labels: Labels{"": "eu"}, wantErr: true},
+		{name: "invalid value", labels: Labels{"region": "eu/west"}, wantErr: true},
+		{name: "value too long", labels: Labels{"region": strings.Repeat("v", 64)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.labels.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
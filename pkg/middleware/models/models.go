@@ -1,14 +1,11 @@
 package models
 
 import (
-	"crypto/sha256"
-	"encoding/base64"
 	"fmt"
 	"gorm.io/gorm"
 	"regexp"
 	"time"
 
-	"middleman/pkg/config"
 	"middleman/pkg/utils"
 )
 
@@ -20,6 +17,18 @@ const (
 	RoleSlave  RoleType = "slave"
 )
 
+const (
+	HealthUnknown   = "unknown"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+const (
+	KeyCurrent  = "current"
+	KeyPrevious = "previous"
+	KeyAPIKey   = "api_key"
+)
+
 func (r RoleType) IsValid() bool {
 	return r == RoleMaster || r == RoleSlave
 }
@@ -37,57 +46,107 @@ type BaseJumpServer struct {
 	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 	Role      RoleType  `json:"role" gorm:"not null"`
 	Endpoint  string    `json:"endpoint" gorm:"not null"`
+	Labels    Labels    `json:"labels" gorm:"type:jsonb;not null;default:'{}'"`
+
+	// 健康检查结果，由后台探测任务更新
+	Status        string     `json:"status" gorm:"size:16;not null;default:unknown;index"`
+	Latency       int64      `json:"latency"`
+	Version       string     `json:"version" gorm:"size:64"`
+	LastError     string     `json:"last_error,omitempty" gorm:"type:text"`
+	LastSeenAt    *time.Time `json:"last_seen_at" gorm:"default:null"`
+	LastCheckedAt *time.Time `json:"last_checked_at" gorm:"default:null"`
 }
 
 type JumpServer struct {
 	BaseJumpServer
+	// PrivateToken 保存的是 SecretBackend 中的引用，使用时通过 GetPrivateToken 读取
 	PrivateToken string `json:"private_token" gorm:"not null"`
-	AccessKey    string `json:"access_key" gorm:"type:varchar(36);not null"`
+	AccessKey    string `json:"access_key" gorm:"type:varchar(36);not null;index"`
 	SecretKey    string `json:"secret_key" gorm:"not null"`
+
+	// 轮换后旧密钥在宽限期内仍然有效
+	PrevAccessKey    string     `json:"-" gorm:"type:varchar(36);index"`
+	PrevSecretKey    string     `json:"-"`
+	PrevKeyExpiredAt *time.Time `json:"prev_key_expired_at,omitempty" gorm:"default:null"`
+}
+
+func (jms *JumpServer) PrevKeyValid() bool {
+	if jms.PrevAccessKey == "" || jms.PrevKeyExpiredAt == nil {
+		return false
+	}
+	return jms.PrevKeyExpiredAt.After(time.Now())
 }
 
 func (jms *JumpServer) GetKey() []byte {
-	conf := config.GetConf()
-	hashed := sha256.Sum256([]byte(conf.BootstrapToken))
-	return hashed[:]
+	_, key := utils.CurrentKey()
+	return key
+}
+
+func (jms *JumpServer) Encrypt(text string) (string, error) {
+	return encryptText(text)
+}
+
+func (jms *JumpServer) Decrypt(text string) (string, error) {
+	return decryptText(text)
+}
+
+// StorePrivateToken 将 Private token 写入配置的 SecretBackend，返回需要保存的引用
+func (jms *JumpServer) StorePrivateToken(token string) (string, error) {
+	return utils.PutSecret(fmt.Sprintf("%s-private-token", jms.Name), token)
+}
+
+// GetPrivateToken 按需从 SecretBackend 读取 Private token，不在查询钩子中读取，
+// 避免认证等只需要密钥的查询依赖 SecretBackend
+func (jms *JumpServer) GetPrivateToken() (string, error) {
+	return utils.GetSecret(jms.PrivateToken)
+}
+
+func encryptText(text string) (string, error) {
+	return utils.EncryptString(text)
+}
+
+func decryptText(text string) (string, error) {
+	return utils.DecryptString(text)
 }
 
 func (jms *JumpServer) BeforeSave(tx *gorm.DB) error {
-	key := jms.GetKey()
-	ciphertext, err := utils.Encrypt([]byte(jms.PrivateToken), key)
-	if err != nil {
-		return fmt.Errorf("加密 Private token 失败: %w", err)
+	// Model(&JumpServer{}).Updates(map) 也会触发钩子，空模型不写入 SecretBackend
+	if jms.Name != "" {
+		ref, err := jms.StorePrivateToken(jms.PrivateToken)
+		if err != nil {
+			return fmt.Errorf("保存 Private token 失败: %w", err)
+		}
+		jms.PrivateToken = ref
 	}
-	jms.PrivateToken = base64.StdEncoding.EncodeToString(ciphertext)
-	ciphertext, err = utils.Encrypt([]byte(jms.SecretKey), key)
+	ciphertext, err := jms.Encrypt(jms.SecretKey)
 	if err != nil {
 		return fmt.Errorf("加密 Secret key 失败: %w", err)
 	}
-	jms.SecretKey = base64.StdEncoding.EncodeToString(ciphertext)
+	jms.SecretKey = ciphertext
+	if jms.PrevSecretKey != "" {
+		ciphertext, err = jms.Encrypt(jms.PrevSecretKey)
+		if err != nil {
+			return fmt.Errorf("加密 Secret key 失败: %w", err)
+		}
+		jms.PrevSecretKey = ciphertext
+	}
 	return nil
 }
 
 func (jms *JumpServer) AfterFind(tx *gorm.DB) error {
-	key := jms.GetKey()
-	text, err := base64.StdEncoding.DecodeString(jms.PrivateToken)
-	if err != nil {
-		return fmt.Errorf("解码失败: %w", err)
-	}
-	plaintext, err := utils.Decrypt(text, key)
+	plaintext, err := jms.Decrypt(jms.SecretKey)
 	if err != nil {
-		return fmt.Errorf("解密失败: %w", err)
+		return err
 	}
-	jms.PrivateToken = plaintext
+	jms.SecretKey = plaintext
 
-	text, err = base64.StdEncoding.DecodeString(jms.SecretKey)
-	if err != nil {
-		return fmt.Errorf("解码失败: %w", err)
+	if jms.PrevSecretKey != "" {
+		plaintext, err = jms.Decrypt(jms.PrevSecretKey)
+		if err != nil {
+			return err
+		}
+		jms.PrevSecretKey = plaintext
 	}
-	plaintext, err = utils.Decrypt(text, key)
-	if err != nil {
-		return fmt.Errorf("解密失败: %w", err)
-	}
-	jms.AccessKey = plaintext
 	return nil
 }
 
@@ -98,5 +157,5 @@ func (jms *JumpServer) Validate() error {
 	if !jms.Name.IsValid() {
 		return fmt.Errorf("name 只能是大小写字母及下划线组成")
 	}
-	return nil
+	return jms.Labels.Validate()
 }
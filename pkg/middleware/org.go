@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"middleman/pkg/consts"
+	"middleman/pkg/database"
+	"middleman/pkg/database/models"
+	mm "middleman/pkg/middleware/models"
+)
+
+const OrgHeader = "X-JMS-ORG"
+
+// OrgMiddleware 通过 X-JMS-ORG 选择组织，未指定时使用默认组织，
+// 在 DatabaseMiddleware 之后使用时，组织必须存在于全部目标分节点中
+func OrgMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID := models.DefaultOrgID
+		if text := strings.TrimSpace(c.GetHeader(OrgHeader)); text != "" {
+			parsed, err := uuid.Parse(text)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("Invalid %s header: %s", OrgHeader, text),
+					"code":  40005,
+				})
+				return
+			}
+			orgID = parsed.String()
+		}
+
+		var servers []mm.JumpServer
+		if targets, exists := c.Get(consts.DBTargetsContextKey); exists {
+			servers = targets.([]mm.JumpServer)
+		} else if server, exists := c.Get(consts.DBInfoContextKey); exists {
+			servers = []mm.JumpServer{server.(mm.JumpServer)}
+		}
+
+		var missing []mm.NameType
+		for _, server := range servers {
+			db, err := database.GetDBManager().GetDB(string(server.Name))
+			if err != nil {
+				// 广播请求中单个分节点的数据库异常由处理函数报告
+				continue
+			}
+			var count int64
+			if err = db.Model(&models.Organization{}).Where("id = ?", orgID).
+				Count(&count).Error; err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("Failed to get organization: %v", err),
+				})
+				return
+			}
+			if count == 0 {
+				missing = append(missing, server.Name)
+			}
+		}
+		if len(missing) > 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":   fmt.Sprintf("Organization %s not found", orgID),
+				"details": missing,
+				"code":    40005,
+			})
+			return
+		}
+
+		c.Set(consts.OrgContextKey, orgID)
+		c.Next()
+	}
+}
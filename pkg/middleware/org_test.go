@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"middleman/pkg/consts"
+	"middleman/pkg/database/models"
+)
+
+func TestOrgMiddleware(t *testing.T) {
+	const orgID = "1c5b7e5a-3f52-4c1e-9f0e-2b6c8d4a9e10"
+	tests := []struct {
+		name    string
+		header  string
+		want    int
+		wantOrg string
+	}{
+		{name: "default org", want: http.StatusOK, wantOrg: models.DefaultOrgID},
+		{name: "blank header", header: "  ", want: http.StatusOK, wantOrg: models.DefaultOrgID},
+		{name: "org id", header: orgID, want: http.StatusOK, wantOrg: orgID},
+		{name: "upper case org id", header: " 1C5B7E5A-3F52-4C1E-9F0E-2B6C8D4A9E10 ", want: http.StatusOK,
+			wantOrg: orgID},
+		{name: "invalid org id", header: "default", want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotOrg string
+			r := gin.New()
+			r.GET("/", OrgMiddleware(), func(c *gin.Context) {
+				gotOrg = c.GetString(consts.OrgContextKey)
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(OrgHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if gotOrg != tt.wantOrg {
+				t.Errorf("org = %q, want %q", gotOrg, tt.wantOrg)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"middleman/pkg/consts"
+	mm "middleman/pkg/middleware/models"
+)
+
+// Rule 声明路由允许访问的节点角色，MTypes 可按资源类型进一步限制角色，
+// NodeKeyOnly 的路由只能使用节点自身的密钥访问，不接受 API key
+type Rule struct {
+	Roles       []mm.RoleType
+	MTypes      map[string][]mm.RoleType
+	NodeKeyOnly bool
+}
+
+// Policy 的 key 为 "METHOD /full/path/"，未声明的路由一律拒绝
+type Policy map[string]Rule
+
+func PolicyKey(method, path string) string {
+	return fmt.Sprintf("%s %s", method, path)
+}
+
+func hasRole(roles []mm.RoleType, role mm.RoleType) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) Allows(role mm.RoleType, mType string) bool {
+	if !hasRole(r.Roles, role) {
+		return false
+	}
+	if roles, ok := r.MTypes[mType]; ok {
+		return hasRole(roles, role)
+	}
+	return true
+}
+
+func PolicyMiddleware(policy Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authServer := c.MustGet(consts.AuthDBInfoContextKey).(mm.JumpServer)
+		rule, exists := policy[PolicyKey(c.Request.Method, c.FullPath())]
+		if !exists || !rule.Allows(authServer.Role, c.Query("m_type")) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": fmt.Sprintf("Role %s is not allowed to access this resource", authServer.Role),
+				"code":  40302,
+			})
+			return
+		}
+		if _, usedAPIKey := c.Get(consts.APIKeyContextKey); usedAPIKey && rule.NodeKeyOnly {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "This resource can only be accessed with the node's own key",
+				"code":  40303,
+			})
+			return
+		}
+		c.Next()
+	}
+}
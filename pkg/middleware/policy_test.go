@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"middleman/pkg/consts"
+	mm "middleman/pkg/middleware/models"
+)
+
+var (
+	testAllRoles   = []mm.RoleType{mm.RoleMaster, mm.RoleSlave}
+	testMasterOnly = []mm.RoleType{mm.RoleMaster}
+)
+
+func TestRuleAllows(t *testing.T) {
+	rule := Rule{
+		Roles:  testAllRoles,
+		MTypes: map[string][]mm.RoleType{"user_unblock": testMasterOnly},
+	}
+	tests := []struct {
+		name  string
+		rule  Rule
+		role  mm.RoleType
+		mType string
+		want  bool
+	}{
+		{name: "slave on shared route", rule: rule, role: mm.RoleSlave, mType: "user", want: true},
+		{name: "master on restricted m_type", rule: rule, role: mm.RoleMaster, mType: "user_unblock", want: true},
+		{name: "slave on restricted m_type", rule: rule, role: mm.RoleSlave, mType: "user_unblock"},
+		{name: "master on master route", rule: Rule{Roles: testMasterOnly}, role: mm.RoleMaster, want: true},
+		{name: "slave on master route", rule: Rule{Roles: testMasterOnly}, role: mm.RoleSlave},
+		{name: "unknown role", rule: rule, role: mm.RoleType("other"), mType: "user"},
+		{name: "empty rule", rule: Rule{}, role: mm.RoleMaster},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Allows(tt.role, tt.mType); got != tt.want {
+				t.Errorf("Allows(%s, %q) = %v, want %v", tt.role, tt.mType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyMiddleware(t *testing.T) {
+	policy := Policy{
+		"GET /nodes/":       {Roles: testMasterOnly, NodeKeyOnly: true},
+		"GET /items/:id/":   {Roles: testAllRoles},
+		"PATCH /items/:id/": {Roles: testAllRoles, MTypes: map[string][]mm.RoleType{"unblock": testMasterOnly}},
+	}
+	tests := []struct {
+		name   string
+		role   mm.RoleType
+		apiKey bool
+		method string
+		target string
+		want   int
+	}{
+		{name: "master route", role: mm.RoleMaster, method: http.MethodGet, target: "/nodes/", want: http.StatusOK},
+		{name: "master route as slave", role: mm.RoleSlave, method: http.MethodGet, target: "/nodes/",
+			want: http.StatusForbidden},
+		{name: "path params", role: mm.RoleSlave, method: http.MethodGet, target: "/items/1/", want: http.StatusOK},
+		{name: "restricted m_type as slave", role: mm.RoleSlave, method: http.MethodPatch,
+			target: "/items/1/?m_type=unblock", want: http.StatusForbidden},
+		{name: "restricted m_type as master", role: mm.RoleMaster, method: http.MethodPatch,
+			target: "/items/1/?m_type=unblock", want: http.StatusOK},
+		{name: "node key only route with api key", role: mm.RoleMaster, apiKey: true, method: http.MethodGet,
+			target: "/nodes/", want: http.StatusForbidden},
+		{name: "api key", role: mm.RoleSlave, apiKey: true, method: http.MethodGet, target: "/items/1/",
+			want: http.StatusOK},
+		{name: "route without policy", role: mm.RoleMaster, method: http.MethodDelete, target: "/items/1/",
+			want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				c.Set(consts.AuthDBInfoContextKey, mm.JumpServer{BaseJumpServer: mm.BaseJumpServer{Role: tt.role}})
+				if tt.apiKey {
+					c.Set(consts.APIKeyContextKey, mm.APIKey{Name: "readonly"})
+				}
+			}, PolicyMiddleware(policy))
+			ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+			r.GET("/nodes/", ok)
+			r.GET("/items/:id/", ok)
+			r.PATCH("/items/:id/", ok)
+			r.DELETE("/items/:id/", ok)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+			if w.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.target, w.Code, tt.want)
+			}
+		})
+	}
+}
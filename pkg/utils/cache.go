@@ -117,6 +117,45 @@ func (c *CacheManager) Get(key string, dest interface{}) error {
 	return json.Unmarshal([]byte(fmt.Sprint(cacheItem.Value)), dest)
 }
 
+// SetIfAbsent 仅在 key 不存在或已过期时写入，返回是否写入成功
+func (c *CacheManager) SetIfAbsent(key string, value interface{}, expireSecond int64) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiration := int64(0)
+	if expireSecond > 0 {
+		expiration = time.Now().Unix() + expireSecond
+	}
+	data, err := json.Marshal(CacheItem{Value: value, Expiration: expiration})
+	if err != nil {
+		return false, err
+	}
+
+	stored := false
+	err = c.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err == nil {
+			var cacheItem CacheItem
+			if err = item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &cacheItem)
+			}); err != nil {
+				return err
+			}
+			if cacheItem.Expiration == 0 || time.Now().Unix() <= cacheItem.Expiration {
+				return nil
+			}
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		stored = true
+		return txn.Set([]byte(key), data)
+	})
+	if err != nil {
+		return false, err
+	}
+	return stored, nil
+}
+
 func (c *CacheManager) Delete(key string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -2,6 +2,7 @@ package utils
 
 import (
     "bytes"
+    "context"
     "encoding/json"
     "fmt"
     "io"
@@ -13,19 +14,37 @@ import (
 type JumpServer struct {
 	endpoint   string
 	privateKey string
+	orgID      string
 	client     *http.Client
 	retryer    *RetryManager
 }
 
+// WithOrg 返回在指定组织下发送请求的客户端
+func (jms *JumpServer) WithOrg(orgID string) *JumpServer {
+	client := *jms
+	client.orgID = orgID
+	return &client
+}
+
 func (jms *JumpServer) getHeaders() map[string]string {
-	return map[string]string{
+	headers := map[string]string{
 		"Content-Type":      "application/json",
 		"Authorization":     "Token " + jms.privateKey,
 		"Middleman-Version": "1.0",
 	}
+	if jms.orgID != "" {
+		headers["X-JMS-ORG"] = jms.orgID
+	}
+	return headers
 }
 
 func (jms *JumpServer) doRequest(method, path string, body interface{}) (*http.Response, error) {
+	return jms.doRequestWithContext(context.Background(), method, path, body)
+}
+
+func (jms *JumpServer) doRequestWithContext(
+	ctx context.Context, method, path string, body interface{},
+) (*http.Response, error) {
 	url := jms.endpoint + path
 
 	var reqBody []byte
@@ -36,7 +55,7 @@ func (jms *JumpServer) doRequest(method, path string, body interface{}) (*http.R
 			return nil, fmt.Errorf("serializer body failed: %w", err)
 		}
 	}
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(reqBody))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return nil, fmt.Errorf("new request failed: %w", err)
 	}
@@ -157,6 +176,53 @@ func (jms *JumpServer) CreateUser(user models.JMSUser) {
 	jms.Post(url, user)
 }
 
+func (jms *JumpServer) UpdateUser(id string, data interface{}) {
+	url := fmt.Sprintf("/api/v1/users/users/%s/", id)
+	jms.Patch(url, data)
+}
+
+func (jms *JumpServer) DeleteUser(id, cacheKey string) {
+	url := fmt.Sprintf("/api/v1/users/users/%s/", id)
+	jms.Delete(url, cacheKey)
+}
+
+func (jms *JumpServer) UpdateUserGroup(id string, data interface{}) {
+	url := fmt.Sprintf("/api/v1/users/groups/%s/", id)
+	jms.Patch(url, data)
+}
+
+func (jms *JumpServer) DeleteUserGroup(id, cacheKey string) {
+	url := fmt.Sprintf("/api/v1/users/groups/%s/", id)
+	jms.Delete(url, cacheKey)
+}
+
+// UserGroupRelation 添加或移除用户组成员
+func (jms *JumpServer) UserGroupRelation(action, groupID string, userIds []string) {
+	url := "/api/v1/users/users-groups-relations/"
+	if action == "add" {
+		relations := make([]map[string]string, 0, len(userIds))
+		for _, userID := range userIds {
+			relations = append(relations, map[string]string{"user": userID, "usergroup": groupID})
+		}
+		jms.Post(url, relations)
+		return
+	}
+	for _, userID := range userIds {
+		jms.Delete(fmt.Sprintf("%s?usergroup=%s&user=%s", url, groupID, userID), "")
+	}
+}
+
+// CreateRoleBinding scope 为 system 或 org，对应不同的角色绑定接口
+func (jms *JumpServer) CreateRoleBinding(scope string, binding models.JMSRoleBinding) {
+	url := fmt.Sprintf("/api/v1/rbac/%s-role-bindings/", scope)
+	jms.Post(url, binding)
+}
+
+func (jms *JumpServer) DeleteRoleBinding(scope, id, cacheKey string) {
+	url := fmt.Sprintf("/api/v1/rbac/%s-role-bindings/%s/", scope, id)
+	jms.Delete(url, cacheKey)
+}
+
 func (jms *JumpServer) CreateChildrenNode(node models.JMSNode) {
 	url := fmt.Sprintf("/api/v1/assets/nodes/%s/children/", node.ParentID)
 	jms.Post(url, node)
@@ -182,18 +248,53 @@ func (jms *JumpServer) UpdatePerm(perm models.JmsAssetPermission) {
 	jms.Put(url, perm)
 }
 
-func (jms *JumpServer) CreateAsset(asset interface{}) {
-	var category string
-	var newAsset models.Asset
-	switch v := asset.(type) {
-	case models.Host:
-		category = "hosts"
-		newAsset = v.Asset
-	default:
+func (jms *JumpServer) CreateAsset(asset models.TypedAsset) {
+	url := fmt.Sprintf("/api/v1/assets/%ss/?platform=%v", asset.Category(), asset.GetAsset().PlatformID)
+	payload, err := models.ToJmsAsset(asset)
+	if err != nil {
+		jms.retryer.logger.Error("Serializer asset %s failed: %s", url, err)
+		return
+	}
+	jms.Post(url, payload)
+}
+
+func (jms *JumpServer) UpdateAsset(asset models.TypedAsset) {
+	url := fmt.Sprintf("/api/v1/assets/%ss/%s/", asset.Category(), asset.GetAsset().ID)
+	payload, err := models.ToJmsAsset(asset)
+	if err != nil {
+		jms.retryer.logger.Error("Serializer asset %s failed: %s", url, err)
 		return
 	}
-	url := fmt.Sprintf("/api/v1/assets/%s/?platform=%v", category, newAsset.PlatformID)
-	jms.Post(url, newAsset.ToJms())
+	jms.Patch(url, payload)
+}
+
+func (jms *JumpServer) CreateAccount(account models.JmsAccount) {
+	jms.Post("/api/v1/accounts/accounts/", account)
+}
+
+func (jms *JumpServer) UpdateAccount(account models.JmsAccount) {
+	url := fmt.Sprintf("/api/v1/accounts/accounts/%s/", account.ID)
+	jms.Patch(url, account)
+}
+
+func (jms *JumpServer) RemoveAccount(id, cacheKey string) {
+	url := fmt.Sprintf("/api/v1/accounts/accounts/%s/", id)
+	jms.Delete(url, cacheKey)
+}
+
+// PushAccounts 将账号的密文推送到资产上
+func (jms *JumpServer) PushAccounts(ids []string) {
+	data := map[string]interface{}{"action": "push", "accounts": ids}
+	jms.Post("/api/v1/accounts/accounts/tasks/", data)
+}
+
+func (jms *JumpServer) CreateOrg(org models.JMSOrganization) {
+	jms.Post("/api/v1/orgs/orgs/", org)
+}
+
+func (jms *JumpServer) UpdateOrg(org models.JMSOrganization) {
+	url := fmt.Sprintf("/api/v1/orgs/orgs/%s/", org.ID)
+	jms.Patch(url, org)
 }
 
 func (jms *JumpServer) NodeWithAssetsRelation(action, nodeID string, data interface{}) {
@@ -221,6 +322,51 @@ func (jms *JumpServer) ResetUserMFA(id string) {
 	_ = jms.Get(url)
 }
 
+func (jms *JumpServer) getJSON(ctx context.Context, path string) (map[string]interface{}, error) {
+	resp, err := jms.doRequestWithContext(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("send request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response failed: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("get failed，status code: %d, body: %s",
+			resp.StatusCode, string(body))
+	}
+	data := map[string]interface{}{}
+	_ = json.Unmarshal(body, &data)
+	return data, nil
+}
+
+// Ping 检查 JumpServer 是否可用，并尽量获取其版本号
+func (jms *JumpServer) Ping(ctx context.Context) (version string, err error) {
+	data, err := jms.getJSON(ctx, "/api/health/")
+	if err != nil {
+		return "", err
+	}
+	if status, ok := data["status"].(bool); ok && !status {
+		return "", fmt.Errorf("jumpserver reports unhealthy status")
+	}
+
+	for _, key := range []string{"version", "VERSION"} {
+		if v, ok := data[key].(string); ok && v != "" {
+			return v, nil
+		}
+	}
+	if settings, err := jms.getJSON(ctx, "/api/v1/settings/public/"); err == nil {
+		for _, key := range []string{"VERSION", "version"} {
+			if v, ok := settings[key].(string); ok {
+				return v, nil
+			}
+		}
+	}
+	return "", nil
+}
+
 func NewJumpServer(endpoint string, privateKey string) *JumpServer {
 	return &JumpServer{
 		endpoint:   endpoint,
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"middleman/pkg/config"
+)
+
+// LegacyKeyVersion 没有版本前缀的密文，密钥由 BootstrapToken 派生
+const LegacyKeyVersion = 0
+
+func DeriveKey(secret string) []byte {
+	hashed := sha256.Sum256([]byte(secret))
+	return hashed[:]
+}
+
+// CurrentKey 返回当前的密钥版本及密钥，未配置 ENCRYPTION_KEY 时兼容使用 BootstrapToken
+func CurrentKey() (int, []byte) {
+	conf := config.GetConf()
+	secret := conf.EncryptionKey
+	if secret == "" {
+		secret = conf.BootstrapToken
+	}
+	version := conf.EncryptionKeyVersion
+	if version <= LegacyKeyVersion {
+		version = LegacyKeyVersion + 1
+	}
+	return version, DeriveKey(secret)
+}
+
+func keyOfVersion(version int) ([]byte, error) {
+	current, key := CurrentKey()
+	switch version {
+	case current:
+		return key, nil
+	case LegacyKeyVersion:
+		return DeriveKey(config.GetConf().BootstrapToken), nil
+	default:
+		return nil, fmt.Errorf("unknown key version %d, please run the rekey command", version)
+	}
+}
+
+// 密文格式为 "v{version}:{base64}"
+func parseCiphertext(text string) (int, []byte, error) {
+	version := LegacyKeyVersion
+	if strings.HasPrefix(text, "v") {
+		if idx := strings.Index(text, ":"); idx > 1 {
+			if v, err := strconv.Atoi(text[1:idx]); err == nil {
+				version, text = v, text[idx+1:]
+			}
+		}
+	}
+	data, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return 0, nil, fmt.Errorf("解码失败: %w", err)
+	}
+	return version, data, nil
+}
+
+func EncryptString(text string) (string, error) {
+	version, key := CurrentKey()
+	ciphertext, err := Encrypt([]byte(text), key)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("v%d:%s", version, base64.StdEncoding.EncodeToString(ciphertext)), nil
+}
+
+func DecryptString(text string) (string, error) {
+	version, data, err := parseCiphertext(text)
+	if err != nil {
+		return "", err
+	}
+	key, err := keyOfVersion(version)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := Decrypt(data, key)
+	if err != nil {
+		return "", fmt.Errorf("解密失败: %w", err)
+	}
+	return plaintext, nil
+}
+
+// ReEncryptString 使用旧密钥解密后以当前密钥重新加密，已是当前密钥的密文保持不变
+func ReEncryptString(text string, oldKey []byte) (string, bool, error) {
+	version, data, err := parseCiphertext(text)
+	if err != nil {
+		return "", false, err
+	}
+	current, key := CurrentKey()
+	if version == current {
+		if _, err = Decrypt(data, key); err == nil {
+			return text, false, nil
+		}
+	}
+	plaintext, err := Decrypt(data, oldKey)
+	if err != nil {
+		return "", false, fmt.Errorf("解密失败: %w", err)
+	}
+	ciphertext, err := EncryptString(plaintext)
+	if err != nil {
+		return "", false, err
+	}
+	return ciphertext, true, nil
+}
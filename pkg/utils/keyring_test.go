@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+
+	"middleman/pkg/config"
+)
+
+// 缓存、日志等目录写入临时目录
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "middleman-utils")
+	if err != nil {
+		panic(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setKeyConfig 替换测试期间使用的密钥配置
+func setKeyConfig(t *testing.T, bootstrapToken, encryptionKey string, version int) {
+	t.Helper()
+	previous := config.GlobalConfig
+	conf := config.GetConf()
+	conf.BootstrapToken = bootstrapToken
+	conf.EncryptionKey = encryptionKey
+	conf.EncryptionKeyVersion = version
+	config.GlobalConfig = &conf
+	t.Cleanup(func() { config.GlobalConfig = previous })
+}
+
+func legacyCiphertext(t *testing.T, text, secret string) string {
+	t.Helper()
+	data, err := Encrypt([]byte(text), DeriveKey(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestCurrentKey(t *testing.T) {
+	tests := []struct {
+		name          string
+		encryptionKey string
+		version       int
+		wantVersion   int
+		wantSecret    string
+	}{
+		{name: "encryption key", encryptionKey: "key-v2", version: 2, wantVersion: 2, wantSecret: "key-v2"},
+		{name: "fallback to bootstrap token", version: 1, wantVersion: 1, wantSecret: "bootstrap"},
+		{name: "legacy version is bumped", encryptionKey: "key", version: 0, wantVersion: 1, wantSecret: "key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setKeyConfig(t, "bootstrap", tt.encryptionKey, tt.version)
+			version, key := CurrentKey()
+			if version != tt.wantVersion {
+				t.Errorf("CurrentKey() version = %d, want %d", version, tt.wantVersion)
+			}
+			if string(key) != string(DeriveKey(tt.wantSecret)) {
+				t.Errorf("CurrentKey() key is not derived from %q", tt.wantSecret)
+			}
+		})
+	}
+}
+
+func TestEncryptDecryptString(t *testing.T) {
+	setKeyConfig(t, "bootstrap", "key-v2", 2)
+
+	for _, text := range []string{"", "password", "中文密码", strings.Repeat("x", 4096)} {
+		ciphertext, err := EncryptString(text)
+		if err != nil {
+			t.Fatalf("EncryptString(%q) error = %v", text, err)
+		}
+		if !strings.HasPrefix(ciphertext, "v2:") {
+			t.Errorf("EncryptString(%q) = %q, want v2 prefix", text, ciphertext)
+		}
+		plaintext, err := DecryptString(ciphertext)
+		if err != nil || plaintext != text {
+			t.Errorf("DecryptString() = %q, %v, want %q", plaintext, err, text)
+		}
+	}
+}
+
+func TestDecryptString(t *testing.T) {
+	setKeyConfig(t, "bootstrap", "key-v2", 2)
+	current, err := EncryptString("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := legacyCiphertext(t, "secret", "bootstrap")
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{name: "current version", text: current, want: "secret"},
+		{name: "legacy without prefix", text: legacy, want: "secret"},
+		{name: "explicit legacy version", text: "v0:" + legacy, want: "secret"},
+		{name: "unknown version", text: "v3:" + legacy, wantErr: true},
+		{name: "wrong key for version", text: "v2:" + legacy, wantErr: true},
+		{name: "invalid base64", text: "v2:not base64", wantErr: true},
+		{name: "too short", text: base64.StdEncoding.EncodeToString([]byte("x")), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecryptString(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecryptString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DecryptString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReEncryptString(t *testing.T) {
+	// 先以旧密钥 v1 加密，再切换到 v2
+	setKeyConfig(t, "bootstrap", "key-v1", 1)
+	oldCiphertext, err := EncryptString("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setKeyConfig(t, "bootstrap", "key-v2", 2)
+	currentCiphertext, err := EncryptString("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		text        string
+		oldKey      []byte
+		wantChanged bool
+		wantErr     bool
+	}{
+		{name: "old key", text: oldCiphertext, oldKey: DeriveKey("key-v1"), wantChanged: true},
+		{name: "legacy ciphertext", text: legacyCiphertext(t, "secret", "bootstrap"),
+			oldKey: DeriveKey("bootstrap"), wantChanged: true},
+		{name: "already current", text: currentCiphertext, oldKey: DeriveKey("key-v1")},
+		{name: "wrong old key", text: oldCiphertext, oldKey: DeriveKey("other"), wantErr: true},
+		{name: "invalid ciphertext", text: "v1:not base64", oldKey: DeriveKey("key-v1"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed, err := ReEncryptString(tt.text, tt.oldKey)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReEncryptString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("ReEncryptString() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if !changed && got != tt.text {
+				t.Errorf("ReEncryptString() = %q, want unchanged %q", got, tt.text)
+			}
+			if changed && !strings.HasPrefix(got, "v2:") {
+				t.Errorf("ReEncryptString() = %q, want v2 prefix", got)
+			}
+			plaintext, err := DecryptString(got)
+			if err != nil || plaintext != "secret" {
+				t.Errorf("DecryptString() = %q, %v, want %q", plaintext, err, "secret")
+			}
+		})
+	}
+}
@@ -65,12 +65,12 @@ func (s *JSONFileStorage) Save(req RequestInfo) {
     
     data, err := json.MarshalIndent(req, "", "  ")
     if err != nil {
-        s.logger.Error("Request save failed: ", err.Error())
+        s.logger.Error("Request save failed: %s", err.Error())
         return
     }
     
     if err = os.WriteFile(req.Filepath, data, 0644); err != nil {
-        s.logger.Error("Request save failed: \n", string(data))
+        s.logger.Error("Request save failed: \n%s", string(data))
         return
     }
     
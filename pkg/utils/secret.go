@@ -0,0 +1,346 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"middleman/pkg/config"
+)
+
+const (
+	InlineSecretBackend = "inline"
+	VaultSecretBackend  = "vault"
+	HTTPSecretBackend   = "http"
+)
+
+// SecretBackend 保存节点的敏感凭据，数据库中只记录 Put 返回的引用
+type SecretBackend interface {
+	Put(name, secret string) (string, error)
+	Get(ref string) (string, error)
+	Delete(ref string) error
+}
+
+var (
+	secretBackends    = map[string]SecretBackend{}
+	secretBackendsMux sync.Mutex
+)
+
+func newSecretBackend(kind string) (SecretBackend, error) {
+	conf := config.GetConf()
+	switch kind {
+	case InlineSecretBackend:
+		return &InlineBackend{}, nil
+	case VaultSecretBackend:
+		return NewVaultBackend(conf.SecretVaultPath, conf.SecretVaultKeyFile)
+	case HTTPSecretBackend:
+		return NewHTTPBackend(conf.SecretHTTPURL, conf.SecretHTTPToken)
+	default:
+		return nil, fmt.Errorf("unknown secret backend: %s", kind)
+	}
+}
+
+func getSecretBackend(kind string) (SecretBackend, error) {
+	secretBackendsMux.Lock()
+	defer secretBackendsMux.Unlock()
+
+	if backend, exists := secretBackends[kind]; exists {
+		return backend, nil
+	}
+	backend, err := newSecretBackend(kind)
+	if err != nil {
+		return nil, err
+	}
+	secretBackends[kind] = backend
+	return backend, nil
+}
+
+// backendOfRef 按引用前缀选择后端，切换 SECRET_BACKEND 后已有数据仍可读取
+func backendOfRef(ref string) (SecretBackend, error) {
+	for _, kind := range []string{VaultSecretBackend, HTTPSecretBackend} {
+		if strings.HasPrefix(ref, kind+":") {
+			return getSecretBackend(kind)
+		}
+	}
+	return getSecretBackend(InlineSecretBackend)
+}
+
+func IsExternalSecret(ref string) bool {
+	return strings.HasPrefix(ref, VaultSecretBackend+":") ||
+		strings.HasPrefix(ref, HTTPSecretBackend+":")
+}
+
+// 读取结果缓存一段时间，避免每次请求都访问外部后端
+const secretCacheTTL = 5 * time.Minute
+
+type cachedSecret struct {
+	value     string
+	expiredAt time.Time
+}
+
+var (
+	secretCache    = map[string]cachedSecret{}
+	secretCacheMux sync.Mutex
+)
+
+func forgetSecret(ref string) {
+	secretCacheMux.Lock()
+	delete(secretCache, ref)
+	secretCacheMux.Unlock()
+}
+
+func PutSecret(name, secret string) (string, error) {
+	backend, err := getSecretBackend(config.GetConf().SecretBackend)
+	if err != nil {
+		return "", err
+	}
+	ref, err := backend.Put(name, secret)
+	if err != nil {
+		return "", err
+	}
+	forgetSecret(ref)
+	return ref, nil
+}
+
+func GetSecret(ref string) (string, error) {
+	secretCacheMux.Lock()
+	cached, exists := secretCache[ref]
+	secretCacheMux.Unlock()
+	if exists && time.Now().Before(cached.expiredAt) {
+		return cached.value, nil
+	}
+
+	backend, err := backendOfRef(ref)
+	if err != nil {
+		return "", err
+	}
+	value, err := backend.Get(ref)
+	if err != nil {
+		return "", err
+	}
+	secretCacheMux.Lock()
+	secretCache[ref] = cachedSecret{value: value, expiredAt: time.Now().Add(secretCacheTTL)}
+	secretCacheMux.Unlock()
+	return value, nil
+}
+
+func DeleteSecret(ref string) error {
+	backend, err := backendOfRef(ref)
+	if err != nil {
+		return err
+	}
+	forgetSecret(ref)
+	return backend.Delete(ref)
+}
+
+// InlineBackend 加密后直接保存在数据库字段中
+type InlineBackend struct{}
+
+func (b *InlineBackend) Put(name, secret string) (string, error) {
+	return EncryptString(secret)
+}
+
+func (b *InlineBackend) Get(ref string) (string, error) {
+	return DecryptString(ref)
+}
+
+func (b *InlineBackend) Delete(ref string) error {
+	return nil
+}
+
+// VaultBackend 本地文件保险库，使用独立的密钥文件加密
+type VaultBackend struct {
+	path string
+	key  []byte
+	mu   sync.Mutex
+}
+
+func NewVaultBackend(path, keyFile string) (*VaultBackend, error) {
+	if keyFile == "" {
+		return nil, fmt.Errorf("SECRET_VAULT_KEY_FILE is required for vault secret backend")
+	}
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read vault key file failed: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create dir for vault: %w", err)
+	}
+	return &VaultBackend{
+		path: path,
+		key:  DeriveKey(strings.TrimSpace(string(content))),
+	}, nil
+}
+
+func (b *VaultBackend) load() (map[string]string, error) {
+	secrets := map[string]string{}
+	data, err := os.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("vault file is broken: %w", err)
+	}
+	return secrets, nil
+}
+
+func (b *VaultBackend) save(secrets map[string]string) error {
+	data, err := json.MarshalIndent(secrets, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := b.path + ".tmp"
+	if err = os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, b.path)
+}
+
+func (b *VaultBackend) Put(name, secret string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	secrets, err := b.load()
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := Encrypt([]byte(secret), b.key)
+	if err != nil {
+		return "", err
+	}
+	secrets[name] = base64.StdEncoding.EncodeToString(ciphertext)
+	if err = b.save(secrets); err != nil {
+		return "", err
+	}
+	return VaultSecretBackend + ":" + name, nil
+}
+
+func (b *VaultBackend) Get(ref string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	secrets, err := b.load()
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimPrefix(ref, VaultSecretBackend+":")
+	text, exists := secrets[name]
+	if !exists {
+		return "", fmt.Errorf("secret %s not found in vault", name)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return "", fmt.Errorf("解码失败: %w", err)
+	}
+	return Decrypt(ciphertext, b.key)
+}
+
+func (b *VaultBackend) Delete(ref string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	secrets, err := b.load()
+	if err != nil {
+		return err
+	}
+	delete(secrets, strings.TrimPrefix(ref, VaultSecretBackend+":"))
+	return b.save(secrets)
+}
+
+// HTTPBackend 外部 KMS 服务，接口约定:
+//
+//	PUT    {url}/secrets/{name}  {"value": "..."}
+//	GET    {url}/secrets/{name}  -> {"value": "..."}
+//	DELETE {url}/secrets/{name}
+type HTTPBackend struct {
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+type httpSecret struct {
+	Value string `json:"value"`
+}
+
+func NewHTTPBackend(endpoint, token string) (*HTTPBackend, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("SECRET_HTTP_URL is required for http secret backend")
+	}
+	return &HTTPBackend{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		token:    token,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (b *HTTPBackend) doRequest(method, name string, body interface{}) ([]byte, error) {
+	var reqBody []byte
+	var err error
+	if body != nil {
+		if reqBody, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	u := fmt.Sprintf("%s/secrets/%s", b.endpoint, url.PathEscape(name))
+	req, err := http.NewRequest(method, u, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("new request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response failed: %w", err)
+	}
+	if resp.StatusCode >= 300 && !(method == http.MethodDelete && resp.StatusCode == 404) {
+		return nil, fmt.Errorf("secret %s %s failed，status code: %d, body: %s",
+			method, name, resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+func (b *HTTPBackend) Put(name, secret string) (string, error) {
+	if _, err := b.doRequest(http.MethodPut, name, httpSecret{Value: secret}); err != nil {
+		return "", err
+	}
+	return HTTPSecretBackend + ":" + name, nil
+}
+
+func (b *HTTPBackend) Get(ref string) (string, error) {
+	name := strings.TrimPrefix(ref, HTTPSecretBackend+":")
+	body, err := b.doRequest(http.MethodGet, name, nil)
+	if err != nil {
+		return "", err
+	}
+	var secret httpSecret
+	if err = json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("parse secret %s failed: %w", name, err)
+	}
+	return secret.Value, nil
+}
+
+func (b *HTTPBackend) Delete(ref string) error {
+	_, err := b.doRequest(http.MethodDelete, strings.TrimPrefix(ref, HTTPSecretBackend+":"), nil)
+	return err
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const SignatureAlgorithm = "MM-HMAC-SHA256"
+
+// StringToSign 拼接待签名字符串: 方法、路径(含查询参数)、请求体摘要、时间戳、随机数
+func StringToSign(method, uri string, body []byte, timestamp, nonce string) string {
+	digest := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method), uri, hex.EncodeToString(digest[:]), timestamp, nonce,
+	}, "\n")
+}
+
+func SignRequest(secret, method, uri string, body []byte, timestamp, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(StringToSign(method, uri, body, timestamp, nonce)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXLSXRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		rows [][]string
+		want [][]string
+	}{
+		{name: "empty", rows: nil, want: [][]string{}},
+		{
+			name: "plain",
+			rows: [][]string{{"username", "name"}, {"admin", "Administrator"}},
+			want: [][]string{{"username", "name"}, {"admin", "Administrator"}},
+		},
+		{
+			name: "escaped text",
+			rows: [][]string{{`<a href="x">&</a>`, "  leading and trailing  ", "换行\n中文"}},
+			want: [][]string{{`<a href="x">&</a>`, "  leading and trailing  ", "换行\n中文"}},
+		},
+		{
+			name: "empty cells",
+			rows: [][]string{{"", "b", ""}, {}},
+			want: [][]string{{"", "b", ""}, nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewXLSXWriter(&buf, "Users & <Hosts>")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range tt.rows {
+				if err = w.WriteRow(row); err != nil {
+					t.Fatal(err)
+				}
+				if err = w.Flush(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ReadXLSX(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("ReadXLSX() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadXLSX() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func buildXLSX(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = io.WriteString(f, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+const testSheetXML = `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>
+<row r="2"><c r="B2"><v>45292</v></c><c r="C2" t="b"><v>1</v></c><c r="D2" t="s"><v>9</v></c></row>
+</sheetData></worksheet>`
+
+// ReadXLSX 需要兼容 Excel 等软件生成的文件：共享字符串、富文本、数字及布尔单元格
+func TestReadXLSX(t *testing.T) {
+	sharedStrings := `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>name</t></si><si><r><t>rich </t></r><r><t>text</t></r></si>
+</sst>`
+	workbook := `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+ xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Data" sheetId="1" r:id="rId3"/></sheets></workbook>`
+	rels := `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId3" Target="/xl/worksheets/data.xml"/>
+</Relationships>`
+	want := [][]string{{"name", "", "rich text"}, {"", "45292", "true", ""}}
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			name: "sheet from relationships",
+			files: map[string]string{
+				"xl/workbook.xml":            workbook,
+				"xl/_rels/workbook.xml.rels": rels,
+				"xl/sharedStrings.xml":       sharedStrings,
+				"xl/worksheets/data.xml":     testSheetXML,
+			},
+			want: want,
+		},
+		{
+			name: "default sheet path",
+			files: map[string]string{
+				"xl/sharedStrings.xml":     sharedStrings,
+				"xl/worksheets/sheet1.xml": testSheetXML,
+			},
+			want: want,
+		},
+		{name: "missing sheet", files: map[string]string{"xl/sharedStrings.xml": sharedStrings}, wantErr: true},
+		{
+			name: "malformed sheet",
+			files: map[string]string{
+				"xl/worksheets/sheet1.xml": "<worksheet><sheetData>",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildXLSX(t, tt.files)
+			got, err := ReadXLSX(bytes.NewReader(data), int64(len(data)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadXLSX() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadXLSX() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("not a zip file", func(t *testing.T) {
+		data := []byte("name,email\n")
+		if _, err := ReadXLSX(bytes.NewReader(data), int64(len(data))); err == nil ||
+			!strings.Contains(err.Error(), "invalid xlsx file") {
+			t.Errorf("ReadXLSX() error = %v, want invalid xlsx file", err)
+		}
+	})
+}
+
+func TestXLSXColumnIndex(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want int
+	}{
+		{ref: "A1", want: 0},
+		{ref: "B12", want: 1},
+		{ref: "Z3", want: 25},
+		{ref: "AA1", want: 26},
+		{ref: "AB12", want: 27},
+		{ref: "XFD1048576", want: 16383},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			if got := xlsxColumnIndex(tt.ref); got != tt.want {
+				t.Errorf("xlsxColumnIndex(%q) = %d, want %d", tt.ref, got, tt.want)
+			}
+		})
+	}
+}